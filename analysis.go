@@ -0,0 +1,83 @@
+package decompile
+
+import (
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// Analysis lazily computes and caches the analyses derived from a control flow
+// graph, such as its node ordering, dominator and post-dominator trees and
+// derived sequence of graphs. Each analysis is computed on first access, after
+// the node ordering has been initialized, and reused until the graph is
+// mutated.
+//
+// Invalidation contract: every mutating method of the graph (e.g. Node,
+// SetEdge, RemoveEdge, SetRoot or ReRoot) advances its version, and all cached
+// analyses are discarded on the next access after the version changed. Changes
+// not made through the methods of the graph (e.g. assigning node fields
+// directly) are not detected. An Analysis is not safe for concurrent use.
+type Analysis[N comparable] struct {
+	g       *graph.Graph[N]
+	version uint64
+	// Cached analyses; zero if not yet computed.
+	ordered   bool
+	dom       *dominator.Tree[N]
+	postDom   *dominator.Tree[N]
+	graphs    []*graph.Graph[N]
+	intervals [][]*Interval[N]
+}
+
+// NewAnalysis returns a lazily computed analysis of the given control flow graph.
+func NewAnalysis[N comparable](g *graph.Graph[N]) *Analysis[N] {
+	return &Analysis[N]{g: g, version: g.Version()}
+}
+
+// Graph returns the control flow graph of the analysis.
+func (a *Analysis[N]) Graph() *graph.Graph[N] {
+	return a.g
+}
+
+// validate discards the cached analyses if the graph has been mutated since
+// they were computed, and initializes the node ordering if necessary.
+func (a *Analysis[N]) validate() {
+	if a.version != a.g.Version() {
+		*a = Analysis[N]{g: a.g, version: a.g.Version()}
+	}
+	if !a.ordered {
+		a.g.InitOrder()
+		a.ordered = true
+	}
+}
+
+// Order initializes the reverse postorder numbering of the graph nodes, unless
+// it is up to date.
+func (a *Analysis[N]) Order() {
+	a.validate()
+}
+
+// Dominators returns the dominator tree of the graph.
+func (a *Analysis[N]) Dominators() *dominator.Tree[N] {
+	a.validate()
+	if a.dom == nil {
+		a.dom = dominator.New(a.g)
+	}
+	return a.dom
+}
+
+// PostDominators returns the post-dominator tree of the graph.
+func (a *Analysis[N]) PostDominators() *dominator.Tree[N] {
+	a.validate()
+	if a.postDom == nil {
+		a.postDom = dominator.NewPostDominator(a.g)
+	}
+	return a.postDom
+}
+
+// DerivedSequence returns the derived sequence of graphs and their intervals.
+func (a *Analysis[N]) DerivedSequence() ([]*graph.Graph[N], [][]*Interval[N]) {
+	a.validate()
+	if a.graphs == nil {
+		a.graphs, a.intervals, _ = DerivedSequence(a.g)
+	}
+	return a.graphs, a.intervals
+}
@@ -0,0 +1,38 @@
+package decompile
+
+import "github.com/nukilabs/decompile/graph"
+
+// CoalesceLinearChains shrinks the given control flow graph by merging each
+// node with exactly one successor with that successor, if the successor has
+// exactly one predecessor, until no such pair remains. The first node of a
+// chain is kept and represents the whole chain; the values of the merged nodes
+// are dropped. Back edges are not merged, nor latches into their loop header,
+// such that loops survive. It returns the number of merges, and initializes
+// the order of the nodes, which is stale afterwards if any nodes were merged.
+func CoalesceLinearChains[N comparable](g *graph.Graph[N]) int {
+	g.InitOrder()
+	merges := 0
+	removed := make(map[*graph.Node[N]]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range ascReversePostOrder(g.Nodes()) {
+			if removed[n] {
+				continue
+			}
+			for g.OutDegree(n) == 1 {
+				succ := g.Successors(n)[0]
+				// A successor branching back to the node is the latch of a loop
+				// headed by the node, which would be lost as a self-edge.
+				if succ == n || succ == g.Root() || g.InDegree(succ) != 1 ||
+					g.IsBackEdgeCandidate(n, succ) || g.HasEdge(succ, n) {
+					break
+				}
+				g.Merge(n, succ)
+				removed[succ] = true
+				merges++
+				changed = true
+			}
+		}
+	}
+	return merges
+}
@@ -0,0 +1,265 @@
+package decompile
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// compoundSuccessor returns the successor y of the 2-way node x which forms a
+// compound (short-circuit) condition together with x. That is, y is itself a
+// 2-way node, x is the only predecessor of y, and x and y share a branch
+// target. The boolean return value indicates success.
+func compoundSuccessor[N comparable](g *graph.Graph[N], x *graph.Node[N]) (*graph.Node[N], bool) {
+	xSuccs := g.Successors(x)
+	if len(xSuccs) != 2 {
+		return nil, false
+	}
+	for i, y := range xSuccs {
+		if y.ID() == x.ID() {
+			continue
+		}
+		ySuccs := g.Successors(y)
+		if len(ySuccs) != 2 {
+			continue
+		}
+		preds := g.Predecessors(y)
+		if len(preds) != 1 || preds[0].ID() != x.ID() {
+			continue
+		}
+		// The other branch target of x must also be a branch target of y.
+		if slices.Contains(ySuccs, xSuccs[1-i]) {
+			return y, true
+		}
+	}
+	return nil, false
+}
+
+// compoundPredecessor returns the predecessor x of the 2-way node y which forms
+// a compound (short-circuit) condition together with y. The boolean return
+// value indicates success.
+func compoundPredecessor[N comparable](g *graph.Graph[N], y *graph.Node[N]) (*graph.Node[N], bool) {
+	preds := g.Predecessors(y)
+	if len(preds) != 1 {
+		return nil, false
+	}
+	x := preds[0]
+	if succ, ok := compoundSuccessor(g, x); ok && succ.ID() == y.ID() {
+		return x, true
+	}
+	return nil, false
+}
+
+// StructureCompoundConditionals detects compound (short-circuit) conditions in
+// the given control flow graph. A 2-way node x forms a compound condition with
+// its successor y if y is itself a 2-way node, x is the only predecessor of y,
+// and x and y share a branch target. Each such pair is returned as a
+// ShortCircuitConditional primitive with entry x and body [x y], the component
+// nodes also recorded as "left" and "right" extra.
+//
+// As edges carry no branch polarity, the operator is derived from the node
+// ordering: if the shared target comes after the other target of y, it is the
+// else branch, i.e. if (x && y), recorded as "and" extra; otherwise it is the
+// then branch, i.e. if (x || y), recorded as "or" extra. The node ordering
+// of the graph must have been initialized (as by InitOrder).
+func StructureCompoundConditionals[N comparable](g *graph.Graph[N]) []Primitive[N] {
+	prims := make([]Primitive[N], 0)
+	for _, x := range ascReversePostOrder(g.Nodes()) {
+		y, ok := compoundSuccessor(g, x)
+		if !ok {
+			continue
+		}
+		var shared, other *graph.Node[N]
+		for _, succ := range g.Successors(y) {
+			if g.HasEdge(x, succ) {
+				shared = succ
+			} else {
+				other = succ
+			}
+		}
+		if other == nil {
+			// Both targets of y are also targets of x.
+			continue
+		}
+		prim := Primitive[N]{
+			Kind:  ShortCircuitConditional,
+			Entry: x.Value,
+			Body:  []N{x.Value, y.Value},
+			Extra: map[string]N{
+				"left":  x.Value,
+				"right": y.Value,
+			},
+		}
+		if shared.Order > other.Order {
+			prim.Extra["and"] = shared.Value
+		} else {
+			prim.Extra["or"] = shared.Value
+		}
+		prims = append(prims, prim)
+	}
+	return prims
+}
+
+// CommonTail returns the merge block on which both arms of the given 2-way
+// conditional converge, i.e. the first block (in terms of node ordering)
+// reachable from both arms along forward edges. The merge block may differ
+// from the structural follow node of the conditional. The boolean return
+// value indicates success.
+func CommonTail[N comparable](g *graph.Graph[N], prim Primitive[N]) (N, bool) {
+	var zero N
+	if prim.Kind != TwoWayConditional {
+		return zero, false
+	}
+	cond, ok := g.GetNode(prim.Entry)
+	if !ok {
+		return zero, false
+	}
+	succs := g.Successors(cond)
+	if len(succs) != 2 {
+		return zero, false
+	}
+	left := forwardReachable(g, succs[0])
+	var tail *graph.Node[N]
+	for _, n := range forwardReachable(g, succs[1]) {
+		if _, ok := left[n.ID()]; !ok {
+			continue
+		}
+		if tail == nil || n.Order < tail.Order {
+			tail = n
+		}
+	}
+	if tail == nil {
+		return zero, false
+	}
+	return tail.Value, true
+}
+
+// forwardReachable returns the nodes reachable from the given node along
+// forward edges (in terms of node ordering), including the node itself.
+func forwardReachable[N comparable](g *graph.Graph[N], from *graph.Node[N]) map[graph.ID[N]]*graph.Node[N] {
+	reached := map[graph.ID[N]]*graph.Node[N]{from.ID(): from}
+	work := newStack[N]()
+	work.push(from)
+	for !work.empty() {
+		n := work.pop()
+		for _, succ := range g.Successors(n) {
+			if _, ok := reached[succ.ID()]; ok || succ.Order <= n.Order {
+				continue
+			}
+			reached[succ.ID()] = succ
+			work.push(succ)
+		}
+	}
+	return reached
+}
+
+// StructureNWayConditionals structures n-way conditionals (e.g. jump tables of
+// switch statements) in the given control flow graph. The follow node of an
+// n-way conditional is the node immediately dominated by the header with the
+// most incoming edges (of at least two), and the cases are the successors of
+// the header. The body holds the cases immediately dominated by the header,
+// excluding the follow; cases which are also entered from outside of the
+// conditional are not part of the body. If the header branches directly to the
+// follow, that branch is the empty default case, recorded as "default" extra.
+// Unlike 2-way conditionals, loop headers are considered, such that dispatch
+// loops structure as a loop around an n-way conditional.
+func StructureNWayConditionals[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) []Primitive[N] {
+	prims := make([]Primitive[N], 0)
+	for _, node := range descReversePostOrder(g.Nodes()) {
+		succs := g.Successors(node)
+		if len(succs) <= 2 {
+			continue
+		}
+		var follow *graph.Node[N]
+		for _, n := range dom.DominatedBy(node) {
+			preds := g.InDegree(n)
+			if preds < 2 {
+				continue
+			}
+			if follow == nil || g.InDegree(follow) < preds ||
+				(g.InDegree(follow) == preds && follow.Order < n.Order) {
+				follow = n
+			}
+		}
+		prim := Primitive[N]{
+			Kind:  NWayConditional,
+			Entry: node.Value,
+			Extra: map[string]N{
+				"cond": node.Value,
+			},
+		}
+		if follow != nil {
+			prim.Exit = follow.Value
+			prim.Extra["follow"] = follow.Value
+		}
+		for i, succ := range ascReversePostOrder(succs) {
+			prim.Extra[fmt.Sprintf("case%d", i)] = succ.Value
+			switch {
+			case follow != nil && succ.ID() == follow.ID():
+				prim.Extra["default"] = succ.Value
+			case dom.DominatorOf(succ) != nil && dom.DominatorOf(succ).ID() == node.ID():
+				prim.Body = append(prim.Body, succ.Value)
+			}
+		}
+		prims = append(prims, prim)
+	}
+	return prims
+}
+
+// structureGuardLadders groups chains of at least two single-armed 2-way
+// conditionals with a sink arm (see StructureTwoWayConditionals), in which each
+// conditional falls through to the next, into guard ladders. The body of a
+// guard ladder holds the guards in order, which are also recorded as "guard%d"
+// extra along with their sinks as "sink%d" extra, and its follow is the follow
+// of the last guard.
+func structureGuardLadders[N comparable](conditionals []Primitive[N]) []Primitive[N] {
+	prims := make([]Primitive[N], 0)
+	guards := make(map[N]Primitive[N])
+	for _, prim := range conditionals {
+		if _, ok := prim.Extra["sink"]; ok && prim.Kind == TwoWayConditional {
+			guards[prim.Entry] = prim
+		}
+	}
+	// A guard falling through to another guard is not the start of a ladder.
+	inner := make(map[N]struct{})
+	for _, guard := range guards {
+		if _, ok := guards[guard.Exit]; ok {
+			inner[guard.Exit] = struct{}{}
+		}
+	}
+	for _, prim := range conditionals {
+		guard, ok := guards[prim.Entry]
+		if !ok {
+			continue
+		}
+		if _, ok := inner[guard.Entry]; ok {
+			continue
+		}
+		ladder := Primitive[N]{
+			Kind:  GuardLadder,
+			Entry: guard.Entry,
+			Extra: make(map[string]N),
+		}
+		seen := make(map[N]struct{})
+		for ok {
+			if _, ok := seen[guard.Entry]; ok {
+				break
+			}
+			seen[guard.Entry] = struct{}{}
+			i := len(ladder.Body)
+			ladder.Body = append(ladder.Body, guard.Entry)
+			ladder.Extra[fmt.Sprintf("guard%d", i)] = guard.Entry
+			ladder.Extra[fmt.Sprintf("sink%d", i)] = guard.Extra["sink"]
+			ladder.Exit = guard.Exit
+			guard, ok = guards[guard.Exit]
+		}
+		if len(ladder.Body) < 2 {
+			continue
+		}
+		ladder.Extra["follow"] = ladder.Exit
+		prims = append(prims, ladder)
+	}
+	return prims
+}
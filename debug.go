@@ -0,0 +1,75 @@
+package decompile
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// DebugReport structures the given control flow graph and returns a human
+// readable report of all intermediate analysis state: the graph with its node
+// ordering, the immediate dominators, each level of the derived sequence of
+// graphs and the resulting primitives. Since structuring modifies the state of
+// the graph and is not idempotent, a copy of the graph is structured, and the
+// given graph is left unchanged (e.g. if it was already structured).
+func DebugReport[N comparable](g *graph.Graph[N]) string {
+	g = g.Clone()
+	var b strings.Builder
+	prims, err := Structure(g)
+	dom := dominator.New(g)
+	nodes := ascReversePostOrder(g.Nodes())
+
+	b.WriteString("graph:\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "  %v (order %d) ->", node, node.Order)
+		for _, succ := range ascReversePostOrder(g.Successors(node)) {
+			fmt.Fprintf(&b, " %v", succ)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("dominators:\n")
+	for _, node := range nodes {
+		if idom := dom.DominatorOf(node); idom != nil {
+			fmt.Fprintf(&b, "  %v: %v\n", node, idom)
+		}
+	}
+
+	b.WriteString("derived sequence:\n")
+	graphs, intervals, _ := DerivedSequence(g)
+	for i := range graphs {
+		fmt.Fprintf(&b, "  G^%d:", i)
+		for _, interval := range intervals[i] {
+			fmt.Fprintf(&b, " %v", interval)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("primitives:\n")
+	for _, prim := range prims {
+		fmt.Fprintf(&b, "  %v %s: body [", prim.Kind, g.Format(prim.Entry))
+		for i, value := range prim.Body {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(g.Format(value))
+		}
+		b.WriteString("]")
+		for _, key := range slices.Sorted(maps.Keys(prim.Extra)) {
+			fmt.Fprintf(&b, ", %s %s", key, g.Format(prim.Extra[key]))
+		}
+		if prim.Parent != nil {
+			fmt.Fprintf(&b, " (in %v %s)", prim.Parent.Kind, g.Format(prim.Parent.Entry))
+		}
+		b.WriteString("\n")
+	}
+
+	if err != nil {
+		fmt.Fprintf(&b, "errors:\n  %v\n", err)
+	}
+	return b.String()
+}
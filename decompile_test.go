@@ -1,7 +1,14 @@
 package decompile
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/nukilabs/decompile/dominator"
@@ -58,6 +65,55 @@ func TestComputeIntervals(t *testing.T) {
 	}
 }
 
+func TestIntervalNodes(t *testing.T) {
+	// Create the loop 2 -> 3 -> 4 -> 2 after the entry 1, with 3 -> 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {3, 5}, {4, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	intervals := Intervals(g)
+	if len(intervals) != 2 {
+		t.Fatalf("expected 2 intervals, got %v", intervals)
+	}
+	var values []int
+	for _, n := range intervals[1].Nodes() {
+		values = append(values, n.Value)
+	}
+	// The header comes first, followed by the nodes in reverse postorder.
+	if !slices.Equal(values, []int{2, 3, 5, 4}) {
+		t.Fatalf("expected nodes [2 3 5 4], got %v", values)
+	}
+	if s := intervals[1].String(); s != "I(2) {2,3,5,4}" {
+		t.Fatalf("expected I(2) {2,3,5,4}, got %s", s)
+	}
+}
+
+func TestIntervalVerify(t *testing.T) {
+	// Create the graph of TestComputeIntervals.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}, {2, 5}, {5, 6}, {6, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	for _, interval := range Intervals(g) {
+		if err := interval.Verify(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// An interval with a second entry at 3 is not single-entry.
+	interval := NewInterval(g.Node(2), g)
+	interval.add(g.Node(3))
+	g.SetEdge(g.Node(1), g.Node(3))
+	if err := interval.Verify(); err == nil {
+		t.Fatalf("expected error for second entry at 3")
+	}
+}
+
 func TestDerivedSequence(t *testing.T) {
 	// Create a simple graph with root 1.
 	g := graph.New[int]()
@@ -84,7 +140,7 @@ func TestDerivedSequence(t *testing.T) {
 	g.SetEdge(f, a)
 
 	// Compute the derived sequence.
-	graphs, intervals := DerivedSequence(g)
+	graphs, intervals, _ := DerivedSequence(g)
 
 	// Check the number of graphs.
 	if len(graphs) != len(intervals) {
@@ -96,6 +152,106 @@ func TestDerivedSequence(t *testing.T) {
 	}
 }
 
+func TestDerivedSequenceTailExit(t *testing.T) {
+	// Create the loop 2 <-> 3 after the entry 1, exited from its tail 3 to the
+	// loop 4 <-> 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 5}, {5, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	graphs, intervals, _ := DerivedSequence(g)
+	if len(graphs) < 2 || len(intervals[0]) != 3 {
+		t.Fatalf("expected intervals I(1), I(2) and I(4), got %v", intervals[0])
+	}
+	// The collapsed node of I(2) exits from 3 to the collapsed node of I(4).
+	if !graphs[1].HasEdge(graphs[1].Interval(1), graphs[1].Interval(2)) {
+		t.Fatalf("expected edge from I(2) to I(4), got\n%v", graphs[1])
+	}
+}
+
+func TestDerivedSequenceValues(t *testing.T) {
+	// Create the loop 2 <-> 3 after the entry 1, exited from its tail 3 to the
+	// loop 4 <-> 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 5}, {5, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	graphs, _, values := DerivedSequence(g)
+	if len(values) != len(graphs) || len(graphs) != 3 {
+		t.Fatalf("expected values for 3 graphs, got %d for %d", len(values), len(graphs))
+	}
+	if v := values[0][g.Node(3).ID()]; !slices.Equal(v, []int{3}) {
+		t.Fatalf("expected node 3 to contain [3], got %v", v)
+	}
+	if v := values[1][graphs[1].Interval(2).ID()]; !slices.Equal(v, []int{4, 5}) {
+		t.Fatalf("expected I(4) to contain [4 5], got %v", v)
+	}
+	if v := values[2][graphs[2].Root().ID()]; !slices.Equal(v, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("expected limit graph to contain [1 2 3 4 5], got %v", v)
+	}
+}
+
+func TestDerivedSequenceN(t *testing.T) {
+	if _, _, _, err := DerivedSequenceN(graph.New[int](), 1); err == nil {
+		t.Fatalf("expected error for graph without root")
+	}
+
+	// Create the chain of loops 1 -> (2 <-> 3) -> (4 <-> 5), which takes two
+	// collapses to reduce to a single node.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 5}, {5, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	graphs, _, _, err := DerivedSequenceN(g, 1)
+	if err == nil || len(graphs) != 2 {
+		t.Fatalf("expected error after 2 graphs, got %d graphs and %v", len(graphs), err)
+	}
+	graphs, _, _, err = DerivedSequenceN(g, g.Len())
+	if err != nil || len(graphs) != 3 {
+		t.Fatalf("expected 3 graphs, got %d graphs and %v", len(graphs), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	graphs, _, _, err = derivedSequence(ctx, g, g.Len())
+	if !errors.Is(err, context.Canceled) || len(graphs) != 1 {
+		t.Fatalf("expected context.Canceled after 1 graph, got %d graphs and %v", len(graphs), err)
+	}
+}
+
+func TestStructureLoopsAcyclic(t *testing.T) {
+	// Create the diamond 1 -> {2, 3} -> 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	// The derived sequence of an acyclic graph is skipped.
+	graphs, intervals, err := loopSequence(context.Background(), g)
+	if graphs != nil || intervals != nil || err != nil {
+		t.Fatalf("expected no derived sequence, got %d graphs and %v", len(graphs), err)
+	}
+	prims, err := StructureLoops(g, dominator.New(g))
+	if err != nil || len(prims) != 0 {
+		t.Fatalf("expected no loops, got %v, %v", prims, err)
+	}
+
+	// Closing the loop 4 -> 1 requires the derived sequence.
+	g.SetEdge(g.Node(4), g.Node(1))
+	if graphs, _, err := loopSequence(context.Background(), g); len(graphs) == 0 || err != nil {
+		t.Fatalf("expected derived sequence, got %d graphs and %v", len(graphs), err)
+	}
+}
+
 func TestStructureLoops(t *testing.T) {
 	// Create a simple graph with root 1.
 	g := graph.New[int]()
@@ -143,7 +299,7 @@ func TestStructureLoops(t *testing.T) {
 	g.SetEdge(n15, n6)
 
 	// Compute the derived sequence.
-	graphs, intervals := DerivedSequence(g)
+	graphs, intervals, _ := DerivedSequence(g)
 
 	for _, graph := range graphs {
 		fmt.Println(graph)
@@ -173,3 +329,1677 @@ func TestStructureLoops(t *testing.T) {
 		fmt.Println(cond)
 	}
 }
+
+func TestLoopConditionBlocks(t *testing.T) {
+	// Create a pre-tested loop with a compound condition: while (2 && 3) { 4 }.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	n4 := g.Node(4)
+	n5 := g.Node(5)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n2, n3)
+	g.SetEdge(n2, n5)
+	g.SetEdge(n3, n4)
+	g.SetEdge(n3, n5)
+	g.SetEdge(n4, n2)
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loop *Primitive[int]
+	for i := range prims {
+		if prims[i].Kind == PreTestedLoop {
+			loop = &prims[i]
+		}
+	}
+	if loop == nil {
+		t.Fatalf("expected pre-tested loop, got %v", prims)
+	}
+	if blocks := LoopConditionBlocks(g, *loop); !slices.Equal(blocks, []int{2, 3}) {
+		t.Fatalf("expected condition blocks [2 3], got %v", blocks)
+	}
+
+	// Create a post-tested loop with a compound condition: do { 2 } while (3 && 4).
+	g = graph.New[int]()
+	n1 = g.Node(1)
+	g.SetRoot(n1)
+	n2 = g.Node(2)
+	n3 = g.Node(3)
+	n4 = g.Node(4)
+	n5 = g.Node(5)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n2, n3)
+	g.SetEdge(n3, n4)
+	g.SetEdge(n3, n5)
+	g.SetEdge(n4, n2)
+	g.SetEdge(n4, n5)
+
+	prims, err = Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop = nil
+	for i := range prims {
+		if prims[i].Kind == PostTestedLoop {
+			loop = &prims[i]
+		}
+	}
+	if loop == nil {
+		t.Fatalf("expected post-tested loop, got %v", prims)
+	}
+	if blocks := LoopConditionBlocks(g, *loop); !slices.Equal(blocks, []int{3, 4}) {
+		t.Fatalf("expected condition blocks [3 4], got %v", blocks)
+	}
+}
+
+func TestAbnormalEntries(t *testing.T) {
+	// Create a conditional 1 whose then-branch 2 leads to the block 6, which is
+	// also entered from outside the conditional by 7.
+	g := graph.New[int]()
+	n0 := g.Node(0)
+	g.SetRoot(n0)
+	n1 := g.Node(1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	n5 := g.Node(5)
+	n6 := g.Node(6)
+	n7 := g.Node(7)
+	g.SetEdge(n0, n1)
+	g.SetEdge(n0, n7)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n1, n3)
+	g.SetEdge(n2, n5)
+	g.SetEdge(n2, n6)
+	g.SetEdge(n3, n5)
+	g.SetEdge(n7, n6)
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := AbnormalEntries(g, prims)
+	if len(edges) != 1 || edges[0] != [2]int{7, 6} {
+		t.Fatalf("expected abnormal entry [7 6], got %v", edges)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	prims := []Primitive[int]{
+		{Kind: PreTestedLoop, Entry: 2, Body: []int{2, 3, 6}, Exit: 7, Extra: map[string]int{"latch": 6, "follow": 7}},
+		{Kind: TwoWayConditional, Entry: 4, Exit: 6, Extra: map[string]int{"cond": 4, "follow": 6}},
+		{Kind: TwoWayConditional, Entry: 3, Body: []int{4}, Exit: 6, Extra: map[string]int{"cond": 3, "follow": 6}},
+	}
+
+	tree := BuildPrimitiveTree(prims)
+	if len(tree.Children) != 1 || tree.Children[0].Primitive.Entry != 2 {
+		t.Fatalf("expected loop 2 as the only outermost primitive")
+	}
+	loop := tree.Children[0]
+	if len(loop.Children) != 1 || loop.Children[0].Primitive.Entry != 3 {
+		t.Fatalf("expected conditional 3 nested in loop 2")
+	}
+	if cond := loop.Children[0]; len(cond.Children) != 1 || cond.Children[0].Primitive.Entry != 4 {
+		t.Fatalf("expected conditional 4 nested in conditional 3")
+	}
+
+	// Create the diamond 1 -> 2 -> {3, 4} -> 5 -> 6.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {3, 5}, {4, 5}, {5, 6}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree = BuildPrimitiveTree(prims)
+	if order := Layout(g, tree); !slices.Equal(order, []int{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("expected layout [1 2 3 4 5 6], got %v", order)
+	}
+	if order := LayoutWeighted(g, tree); !slices.Equal(order, []int{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("expected weighted layout without weights [1 2 3 4 5 6], got %v", order)
+	}
+	// Make the else-branch 4 the hot successor of the conditional 2.
+	g.SetEdgeWeight(g.Node(2), g.Node(3), 1)
+	g.SetEdgeWeight(g.Node(2), g.Node(4), 9)
+	if order := LayoutWeighted(g, tree); !slices.Equal(order, []int{1, 2, 4, 3, 5, 6}) {
+		t.Fatalf("expected weighted layout [1 2 4 3 5 6], got %v", order)
+	}
+}
+
+func TestCommonTail(t *testing.T) {
+	// Create a conditional 1 whose arms 2 and 3 both jump to 4.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	n4 := g.Node(4)
+	n5 := g.Node(5)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n1, n3)
+	g.SetEdge(n2, n4)
+	g.SetEdge(n3, n4)
+	g.SetEdge(n4, n5)
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 {
+		t.Fatalf("expected 1 primitive, got %d", len(prims))
+	}
+	if tail, ok := CommonTail(g, prims[0]); !ok || tail != 4 {
+		t.Fatalf("expected common tail 4, got %v", tail)
+	}
+}
+
+func TestRegisterStructurer(t *testing.T) {
+	const Dispatch = UserPrimitiveKind + 1
+	if err := RegisterPrimitiveKind(Dispatch, "Dispatch"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterPrimitiveKind(Dispatch) })
+	if err := RegisterPrimitiveKind(TwoWayConditional, "Conditional"); err == nil {
+		t.Fatal("expected error registering reserved primitive kind")
+	}
+	if Dispatch.String() != "Dispatch" {
+		t.Fatalf("expected Dispatch, got %s", Dispatch)
+	}
+	t.Cleanup(RegisterStructurer(func(g *graph.Graph[string], dom *dominator.Tree[string]) []Primitive[string] {
+		if g.Root() == nil {
+			return nil
+		}
+		return []Primitive[string]{{Kind: Dispatch, Entry: g.Root().Value}}
+	}))
+
+	g := graph.New[string]()
+	g.SetRoot(g.Node("entry"))
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 || prims[0].Kind != Dispatch || prims[0].Entry != "entry" {
+		t.Fatalf("expected registered Dispatch primitive, got %v", prims)
+	}
+
+	// Unregistered kinds and passes no longer apply.
+	UnregisterPrimitiveKind(Dispatch)
+	if Dispatch.String() != "Unknown" {
+		t.Fatalf("expected Unknown, got %s", Dispatch)
+	}
+	unregister := RegisterStructurer(func(g *graph.Graph[string], dom *dominator.Tree[string]) []Primitive[string] {
+		return []Primitive[string]{{Kind: Dispatch}}
+	})
+	unregister()
+	unregister()
+	if prims, _ := Structure(g); len(prims) != 1 {
+		t.Fatalf("expected only the first registered pass to run, got %v", prims)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	// Create a 2-way conditional 2 with arms 3 and 4, preceded by 1 and
+	// followed by 5 and 6.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	n4 := g.Node(4)
+	n5 := g.Node(5)
+	n6 := g.Node(6)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n2, n3)
+	g.SetEdge(n2, n4)
+	g.SetEdge(n3, n5)
+	g.SetEdge(n4, n5)
+	g.SetEdge(n5, n6)
+
+	values := func(region *RegionNode[int]) []int {
+		var vs []int
+		for _, n := range region.Nodes {
+			vs = append(vs, n.Value)
+		}
+		slices.Sort(vs)
+		return vs
+	}
+
+	tree := Regions(g)
+	if len(tree.Nodes) != 6 {
+		t.Fatalf("expected 6 nodes in root region, got %d", len(tree.Nodes))
+	}
+	var cond *RegionNode[int]
+	for _, region := range tree.Children {
+		t.Log(values(region))
+		if slices.Equal(values(region), []int{2, 3, 4, 5}) {
+			cond = region
+		}
+	}
+	if cond == nil {
+		t.Fatal("expected region {2 3 4 5}")
+	}
+	if len(cond.Children) != 2 {
+		t.Fatalf("expected 2 nested regions, got %d", len(cond.Children))
+	}
+	for _, arm := range cond.Children {
+		if vs := values(arm); !slices.Equal(vs, []int{3}) && !slices.Equal(vs, []int{4}) {
+			t.Fatalf("expected arm region {3} or {4}, got %v", vs)
+		}
+	}
+}
+
+func TestDispatchLoop(t *testing.T) {
+	// Create an interpreter loop: the dispatch 2 branches to the cases 3, 4, 5
+	// and 6, each of which jumps back to the dispatch.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	g.SetEdge(n1, n2)
+	for i := 3; i <= 6; i++ {
+		n := g.Node(i)
+		g.SetEdge(n2, n)
+		g.SetEdge(n, n2)
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loop, dispatch *Primitive[int]
+	for i := range prims {
+		switch prims[i].Kind {
+		case EndlessLoop:
+			loop = &prims[i]
+		case NWayConditional:
+			dispatch = &prims[i]
+		}
+	}
+	if loop == nil || loop.Entry != 2 {
+		t.Fatalf("expected endless loop with header 2, got %v", prims)
+	}
+	body := slices.Sorted(slices.Values(loop.Body))
+	if !slices.Equal(body, []int{2, 3, 4, 5, 6}) {
+		t.Fatalf("expected loop body [2 3 4 5 6], got %v", body)
+	}
+	if dispatch == nil || dispatch.Entry != 2 {
+		t.Fatalf("expected n-way conditional with header 2, got %v", prims)
+	}
+	if cases := slices.Sorted(slices.Values(dispatch.Body)); !slices.Equal(cases, []int{3, 4, 5, 6}) {
+		t.Fatalf("expected cases [3 4 5 6], got %v", cases)
+	}
+	tree := BuildPrimitiveTree(prims)
+	if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 {
+		t.Fatal("expected n-way conditional nested in endless loop")
+	}
+}
+
+func TestLoopUpdateBlock(t *testing.T) {
+	// structure returns the pre-tested loop of the given edges with root 1.
+	structure := func(edges [][2]int) (*graph.Graph[int], Primitive[int]) {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+		prims, err := Structure(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, prim := range prims {
+			if prim.Kind == PreTestedLoop {
+				return g, prim
+			}
+		}
+		t.Fatalf("expected pre-tested loop, got %v", prims)
+		return nil, Primitive[int]{}
+	}
+
+	// for (init; 2; 4) { 3 }
+	g, loop := structure([][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 4}, {4, 2}})
+	if update, ok := LoopUpdateBlock(g, loop); !ok || update != 4 {
+		t.Fatalf("expected update block 4, got %v", update)
+	}
+
+	// while (2) { 3 }
+	g, loop = structure([][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 2}})
+	if update, ok := LoopUpdateBlock(g, loop); ok {
+		t.Fatalf("expected no update block, got %v", update)
+	}
+}
+
+func TestEmitUnstructured(t *testing.T) {
+	// Create a conditional 1 whose arms 2 and 3 never merge.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	g.SetEdge(n1, g.Node(2))
+	g.SetEdge(n1, g.Node(3))
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 || prims[0].Kind != UnresolvedConditional || prims[0].Entry != 1 {
+		t.Fatalf("expected unresolved conditional with entry 1, got %v", prims)
+	}
+
+	prims, err = StructureWithOptions(g, Options[int]{EmitUnstructured: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 || prims[0].Kind != None || prims[0].Entry != 1 {
+		t.Fatalf("expected unstructured primitive with entry 1, got %v", prims)
+	}
+	if body := slices.Sorted(slices.Values(prims[0].Body)); !slices.Equal(body, []int{2, 3}) {
+		t.Fatalf("expected body [2 3], got %v", body)
+	}
+}
+
+func TestLoopLatches(t *testing.T) {
+	// Create a loop with header 2 whose body 3 continues early from 3 and at
+	// the end of the body from 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 2}, {3, 4}, {4, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prim := range prims {
+		if prim.Kind != PreTestedLoop {
+			continue
+		}
+		latches := []int{prim.Extra["latch0"], prim.Extra["latch1"]}
+		if !slices.Equal(latches, []int{3, 4}) {
+			t.Fatalf("expected latches [3 4], got %v", latches)
+		}
+		if _, ok := prim.Extra["latch2"]; ok {
+			t.Fatal("expected 2 latches")
+		}
+		return
+	}
+	t.Fatalf("expected pre-tested loop, got %v", prims)
+}
+
+func TestLoopBody(t *testing.T) {
+	for _, test := range []struct {
+		edges  [][2]int
+		kind   PrimitiveKind
+		body   []int
+		follow int
+	}{
+		// The while loop 2 -> 3 -> 4 -> 2 is followed by 5 -> 6, which is
+		// dominated by the header but not part of the loop.
+		{[][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 4}, {4, 2}, {5, 6}}, PreTestedLoop, []int{2, 3, 4}, 5},
+		// The endless loop 2 -> 3 -> 4 -> 2 is left from 3 to 5 -> 6.
+		{[][2]int{{1, 2}, {2, 3}, {3, 4}, {3, 5}, {4, 2}, {5, 6}}, ConditionalEndlessLoop, []int{2, 3, 4}, 5},
+	} {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range test.edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+		prims, err := Structure(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Entry == 2 })
+		if i == -1 || prims[i].Kind != test.kind {
+			t.Fatalf("%v: expected %v at 2, got %v", test.edges, test.kind, prims)
+		}
+		if !slices.Equal(prims[i].Body, test.body) {
+			t.Fatalf("%v: expected body %v, got %v", test.edges, test.body, prims[i].Body)
+		}
+		if follow, ok := prims[i].Extra["follow"]; !ok || follow != test.follow {
+			t.Fatalf("%v: expected follow %d, got %v", test.edges, test.follow, prims[i].Extra)
+		}
+	}
+}
+
+func TestEarlyReturn(t *testing.T) {
+	// Create a conditional 2 which returns early to 3 and otherwise falls
+	// through to the conditional 4 with arms 5 and 6 merging at 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {4, 5}, {4, 6}, {5, 7}, {6, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prim := range prims {
+		if prim.Kind != TwoWayConditional || prim.Entry != 2 {
+			continue
+		}
+		if prim.Exit != 4 || prim.Extra["sink"] != 3 {
+			t.Fatalf("expected follow 4 and sink 3, got %v and %v", prim.Exit, prim.Extra["sink"])
+		}
+		return
+	}
+	t.Fatalf("expected conditional with entry 2, got %v", prims)
+}
+
+func TestOrderSuccessors(t *testing.T) {
+	// Order the successors ascendingly and descendingly, such that 5 and 6 is
+	// the first exit, respectively.
+	ascending := func(from int, succs []int) []int {
+		return slices.Sorted(slices.Values(succs))
+	}
+	descending := func(from int, succs []int) []int {
+		succs = slices.Sorted(slices.Values(succs))
+		slices.Reverse(succs)
+		return succs
+	}
+	for want, order := range map[int]func(int, []int) []int{5: ascending, 6: descending} {
+		// Create an endless loop with header 2 and latch 4, which is left from
+		// 3 to either 5 or 6, both of which are also reached from 1.
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range [][2]int{{1, 2}, {1, 5}, {1, 6}, {2, 3}, {3, 4}, {3, 5}, {3, 6}, {4, 2}} {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+
+		prims, err := StructureWithOptions(g, Options[int]{OrderSuccessors: order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == ConditionalEndlessLoop })
+		if i == -1 {
+			t.Fatalf("expected conditional endless loop, got %v", prims)
+		}
+		if prims[i].Exit != want {
+			t.Fatalf("expected follow %d, got %d", want, prims[i].Exit)
+		}
+	}
+}
+
+func TestLoopInvariantBlocks(t *testing.T) {
+	// Create the loop 2 -> {3, 6} -> 2, with the body block 4 entered from 3
+	// and the body block 6 entered only from 5 outside of the loop.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 5}, {2, 3}, {3, 4}, {4, 2}, {5, 6}, {6, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	loop := Primitive[int]{Kind: EndlessLoop, Entry: 2, Body: []int{2, 3, 4, 6}}
+
+	// 3 is entered from the header only, 4 from 3 within the loop, and 6 is
+	// not entered from the header at all.
+	if blocks := LoopInvariantBlocks(g, loop); !slices.Equal(blocks, []int{3}) {
+		t.Fatalf("expected invariant blocks [3], got %v", blocks)
+	}
+}
+
+func TestLoopExitAmbiguities(t *testing.T) {
+	// Create a loop with header 2 and body 3, whose exit 5 is also reached by
+	// skipping the loop from 1.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 5}, {2, 3}, {2, 5}, {3, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prim := range prims {
+		if prim.Kind != PreTestedLoop {
+			continue
+		}
+		if exits := LoopExitAmbiguities(g, prim); !slices.Equal(exits, []int{5}) {
+			t.Fatalf("expected ambiguous exits [5], got %v", exits)
+		}
+		return
+	}
+	t.Fatalf("expected pre-tested loop, got %v", prims)
+}
+
+func TestFollowStrategy(t *testing.T) {
+	for want, strategy := range map[int]FollowStrategy{5: FollowHighestOrder, 4: FollowBFS} {
+		// Create a conditional 1 whose arms 2 and 3 merge at 4, from which
+		// control continues to 5, which is also reached early from 2.
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {2, 5}, {3, 4}, {4, 5}} {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+
+		prims, err := StructureWithOptions(g, Options[int]{FollowStrategy: strategy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Entry == 1 })
+		if i == -1 {
+			t.Fatalf("expected conditional with entry 1, got %v", prims)
+		}
+		if prims[i].Exit != want {
+			t.Fatalf("expected follow %d, got %d", want, prims[i].Exit)
+		}
+	}
+}
+
+func TestRedirectPreheaderContinues(t *testing.T) {
+	// Create a loop with preheader 2, header 3 and body 4, 5, where 4
+	// continues to the preheader and 5 to the header.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {3, 6}, {4, 2}, {4, 5}, {5, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	redirected := RedirectPreheaderContinues(g)
+	if len(redirected) != 1 || redirected[[2]int{4, 2}] != 3 {
+		t.Fatalf("expected edge (4, 2) to be redirected to 3, got %v", redirected)
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loops []Primitive[int]
+	for _, prim := range prims {
+		switch prim.Kind {
+		case PreTestedLoop, PostTestedLoop, EndlessLoop, ConditionalEndlessLoop:
+			loops = append(loops, prim)
+		}
+	}
+	if len(loops) != 1 || loops[0].Entry != 3 {
+		t.Fatalf("expected single loop with header 3, got %v", loops)
+	}
+
+	for _, edges := range [][][2]int{
+		// The do-while loop 2 -> 3 -> 2 has a single latch 3.
+		{{1, 2}, {2, 3}, {3, 2}, {3, 4}},
+		// The endless loop 2 -> 3 -> 4 -> 2 has a single latch 4.
+		{{1, 2}, {2, 3}, {3, 4}, {4, 2}},
+	} {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+		if redirected := RedirectPreheaderContinues(g); len(redirected) != 0 {
+			t.Fatalf("%v: expected no redirected edges, got %v", edges, redirected)
+		}
+	}
+}
+
+func TestComputeFollow(t *testing.T) {
+	tests := []struct {
+		name   string
+		edges  [][2]int
+		prim   Primitive[int]
+		follow int
+		ok     bool
+	}{
+		// The arms 2 and 3 of the conditional 1 merge at 4.
+		{
+			"conditional",
+			[][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}},
+			Primitive[int]{Kind: TwoWayConditional, Entry: 1},
+			4, true,
+		},
+		// The loop 2 <-> 3 is left from its latch 3 to 4, such that the
+		// immediate post-dominator of the header is in the loop body.
+		{
+			"loop",
+			[][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}},
+			Primitive[int]{Kind: PostTestedLoop, Entry: 2, Body: []int{2, 3}},
+			4, true,
+		},
+		// The arms 2 and 3 of the conditional 1 never merge, such that it is
+		// only post-dominated by the virtual exit.
+		{
+			"none",
+			[][2]int{{1, 2}, {1, 3}},
+			Primitive[int]{Kind: TwoWayConditional, Entry: 1},
+			0, false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New[int]()
+			g.SetRoot(g.Node(1))
+			for _, edge := range test.edges {
+				g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+			}
+			pdom := dominator.NewPostDominator(g)
+			follow, ok := test.prim.ComputeFollow(g, pdom)
+			if ok != test.ok || follow != test.follow {
+				t.Fatalf("expected follow %d (%v), got %d (%v)", test.follow, test.ok, follow, ok)
+			}
+		})
+	}
+}
+
+func TestFollows(t *testing.T) {
+	prims := []Primitive[int]{
+		{Kind: TwoWayConditional, Entry: 1, Exit: 4, Extra: map[string]int{"cond": 1, "follow": 4}},
+		{Kind: EndlessLoop, Entry: 5, Extra: map[string]int{"latch": 6}},
+		{Kind: PreTestedLoop, Entry: 2, Exit: 4, Extra: map[string]int{"latch": 3, "follow": 4}},
+	}
+	if follows := Follows(prims); !slices.Equal(follows, []int{4, 4}) {
+		t.Fatalf("expected follows [4 4], got %v", follows)
+	}
+}
+
+func TestPrimitiveParent(t *testing.T) {
+	// Create a loop with header 2 containing a conditional 3 with arms 4 and 5
+	// merging at the latch 6.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 7}, {3, 4}, {3, 5}, {4, 6}, {5, 6}, {6, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prim := range prims {
+		switch prim.Kind {
+		case PreTestedLoop:
+			if prim.Parent != nil {
+				t.Fatalf("expected loop without parent, got %v", prim.Parent.Entry)
+			}
+		case TwoWayConditional:
+			if prim.Parent == nil || prim.Parent.Kind != PreTestedLoop || prim.Parent.Entry != 2 {
+				t.Fatalf("expected conditional within loop 2, got %v", prim.Parent)
+			}
+		}
+	}
+}
+
+func TestSingleNode(t *testing.T) {
+	// Create a single-block function without edges.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 0 {
+		t.Fatalf("expected no primitives, got %v", prims)
+	}
+	if graphs, _, _ := DerivedSequence(g); len(graphs) != 1 {
+		t.Fatalf("expected derived sequence of 1 graph, got %d", len(graphs))
+	}
+}
+
+func TestExitEdgeContext(t *testing.T) {
+	// Create an endless loop with header 2 and latch 5, containing a
+	// conditional 3 whose then-arm 4 breaks out of the loop to 8, which is
+	// also reached from 1.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 8}, {2, 3}, {3, 4}, {3, 5}, {4, 5}, {4, 8}, {5, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == ConditionalEndlessLoop })
+	if i == -1 {
+		t.Fatalf("expected conditional endless loop, got %v", prims)
+	}
+	context := ExitEdgeContext(g, prims, prims[i])
+	if len(context) != 1 || context[4] != 4 {
+		t.Fatalf("expected exit from 4 within conditional 4, got %v", context)
+	}
+}
+
+func TestConditionalEndlessLoop(t *testing.T) {
+	tests := []struct {
+		edges [][2]int
+		want  PrimitiveKind
+	}{
+		// An endless loop 2 -> 3 -> 4 -> 2 without exit.
+		{[][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}}, EndlessLoop},
+		// The same loop, which is left by a break from 3 to 5.
+		{[][2]int{{1, 2}, {1, 5}, {2, 3}, {3, 4}, {3, 5}, {4, 2}}, ConditionalEndlessLoop},
+	}
+	for _, test := range tests {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range test.edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+
+		prims, err := Structure(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Entry == 2 })
+		if i == -1 || prims[i].Kind != test.want {
+			t.Fatalf("expected %v with header 2, got %v", test.want, prims)
+		}
+	}
+}
+
+func TestLoopDominatedExterior(t *testing.T) {
+	// Create a loop with header 2 and body 3, which breaks from 3 to the
+	// landing pad 4 and otherwise leaves the loop from 2 to 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 2}, {3, 4}, {4, 5}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	g.InitOrder()
+	loop := Primitive[int]{Kind: PreTestedLoop, Entry: 2, Body: []int{2, 3}}
+	exterior := LoopDominatedExterior(g, dominator.New(g), loop)
+	if !slices.Equal(exterior, []int{4, 5}) {
+		t.Fatalf("expected exterior [4 5], got %v", exterior)
+	}
+}
+
+func TestFollowIsExit(t *testing.T) {
+	// Create a conditional 1 whose arms 2 and 3 both return through the
+	// shared return block 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 || prims[0].Exit != 4 {
+		t.Fatalf("expected conditional with follow 4, got %v", prims)
+	}
+	if exit, ok := prims[0].Extra["follow_is_exit"]; !ok || exit != 4 {
+		t.Fatalf("expected follow 4 to be exit, got %v", prims[0].Extra)
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+
+	a := NewAnalysis(g)
+	dom := a.Dominators()
+	if a.Dominators() != dom {
+		t.Fatal("expected cached dominator tree")
+	}
+	if idom := dom.DominatorOf(g.Node(2)); idom == nil || idom.Value != 1 {
+		t.Fatalf("expected 1 to dominate 2, got %v", idom)
+	}
+
+	// Mutating the graph invalidates the cached analyses.
+	g.SetEdge(g.Node(2), g.Node(3))
+	dom = a.Dominators()
+	if idom := dom.DominatorOf(g.Node(3)); idom == nil || idom.Value != 2 {
+		t.Fatalf("expected 2 to dominate 3, got %v", idom)
+	}
+	if g.Node(3).Order == 0 {
+		t.Fatal("expected node order to be initialized")
+	}
+}
+
+func TestGuardLadder(t *testing.T) {
+	// Create the guards 1 and 3, which return early to 2 and 4, respectively,
+	// before the body 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {3, 4}, {3, 5}, {5, 6}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == GuardLadder })
+	if i == -1 {
+		t.Fatalf("expected guard ladder, got %v", prims)
+	}
+	ladder := prims[i]
+	if ladder.Entry != 1 || !slices.Equal(ladder.Body, []int{1, 3}) || ladder.Exit != 5 {
+		t.Fatalf("expected guard ladder 1 with guards [1 3] and follow 5, got %v", ladder)
+	}
+	if ladder.Extra["sink0"] != 2 || ladder.Extra["sink1"] != 4 {
+		t.Fatalf("expected sinks 2 and 4, got %v", ladder.Extra)
+	}
+}
+
+func TestSingleBreakEndlessLoop(t *testing.T) {
+	// Create an endless loop 2 -> 3 -> 4 -> 2 with a single break from 3 to 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 5}, {2, 3}, {3, 4}, {3, 5}, {4, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == ConditionalEndlessLoop })
+	if i == -1 {
+		t.Fatalf("expected conditional endless loop, got %v", prims)
+	}
+	loop := prims[i]
+	if follow, ok := loop.Extra["follow"]; !ok || follow != 5 || loop.Exit != 5 {
+		t.Fatalf("expected exit and follow 5, got %v and %v", loop.Exit, loop.Extra)
+	}
+	if loop.MayExecuteZeroTimes() {
+		t.Fatal("expected endless loop to execute at least once")
+	}
+}
+
+func TestUncoveredNodes(t *testing.T) {
+	// Create the sequence 1, 2 before the conditional 3 with arms 4 and 5
+	// merging at 6, followed by 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {3, 5}, {4, 6}, {5, 6}, {6, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncovered := UncoveredNodes(g, prims); !slices.Equal(uncovered, []int{1, 2, 7}) {
+		t.Fatalf("expected uncovered nodes [1 2 7], got %v", uncovered)
+	}
+}
+
+func TestSCC(t *testing.T) {
+	// Create the irreducible loop 2 <-> 3 entered at both 2 and 3, followed
+	// by 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 3}, {3, 2}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	sccs := SCC(g)
+	if len(sccs) != 3 {
+		t.Fatalf("expected 3 components, got %v", sccs)
+	}
+	var loop []int
+	for _, n := range sccs[0] {
+		loop = append(loop, n.Value)
+	}
+	if slices.Sort(loop); !slices.Equal(loop, []int{2, 3}) {
+		t.Fatalf("expected largest component [2 3], got %v", loop)
+	}
+}
+
+func TestNaturalLoop(t *testing.T) {
+	// Create a loop with header 2, body 3, 4, 5 and latch 6, left from 2 to 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 7}, {3, 4}, {3, 5}, {4, 6}, {5, 6}, {6, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	var loop []int
+	for _, n := range NaturalLoop(g, g.Node(2), g.Node(6)) {
+		loop = append(loop, n.Value)
+	}
+	if loop[0] != 2 || !slices.Equal(slices.Sorted(slices.Values(loop)), []int{2, 3, 4, 5, 6}) {
+		t.Fatalf("expected loop [2 3 4 5 6] with header first, got %v", loop)
+	}
+
+	// A self-loop consists of its header only.
+	g.SetEdge(g.Node(7), g.Node(7))
+	if loop := NaturalLoop(g, g.Node(7), g.Node(7)); len(loop) != 1 {
+		t.Fatalf("expected self-loop of 1 node, got %v", loop)
+	}
+}
+
+func TestNWayConditional(t *testing.T) {
+	// Create a switch 1 with cases 2, 3 and 4 merging at 5, to which the
+	// default case branches directly.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {1, 4}, {1, 5}, {2, 5}, {3, 5}, {4, 5}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prims) != 1 || prims[0].Kind != NWayConditional || prims[0].Exit != 5 {
+		t.Fatalf("expected n-way conditional with follow 5, got %v", prims)
+	}
+	if body := slices.Sorted(slices.Values(prims[0].Body)); !slices.Equal(body, []int{2, 3, 4}) {
+		t.Fatalf("expected cases [2 3 4], got %v", body)
+	}
+	if def, ok := prims[0].Extra["default"]; !ok || def != 5 {
+		t.Fatalf("expected default case 5, got %v", prims[0].Extra)
+	}
+}
+
+func TestNWayHeaderLoop(t *testing.T) {
+	// Create a do-while loop whose header 2 switches to 3, 4 and 5, merging
+	// at the conditional latch 6.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {2, 5}, {3, 6}, {4, 6}, {5, 6}, {6, 2}, {6, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == PostTestedLoop })
+	if i == -1 || prims[i].Entry != 2 || prims[i].Exit != 7 {
+		t.Fatalf("expected post-tested loop 2 with follow 7, got %v", prims)
+	}
+}
+
+func TestCompoundConditionals(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges [][2]int
+		op    string
+	}{
+		// if (1 && 2) { 3 } 4
+		{"and", [][2]int{{1, 2}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}, "and"},
+		// if (1 || 2) { 3 } 4
+		{"or", [][2]int{{1, 2}, {1, 3}, {2, 3}, {2, 4}, {3, 4}}, "or"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New[int]()
+			g.SetRoot(g.Node(1))
+			for _, edge := range test.edges {
+				g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+			}
+			g.InitOrder()
+			prims := StructureCompoundConditionals(g)
+			if len(prims) != 1 || prims[0].Kind != ShortCircuitConditional {
+				t.Fatalf("expected one short-circuit conditional, got %v", prims)
+			}
+			if !slices.Equal(prims[0].Body, []int{1, 2}) {
+				t.Fatalf("expected components [1 2], got %v", prims[0].Body)
+			}
+			if _, ok := prims[0].Extra[test.op]; !ok {
+				t.Fatalf("expected %q operator, got %v", test.op, prims[0].Extra)
+			}
+		})
+	}
+}
+
+func TestIrreducible(t *testing.T) {
+	// Create the irreducible loop 2 <-> 3, entered at both 2 and 3 from 1.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 3}, {3, 2}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if IsReducible(g) {
+		t.Fatal("expected irreducible graph")
+	}
+	_, err := Structure(g)
+	if !errors.Is(err, ErrIrreducible) {
+		t.Fatalf("expected ErrIrreducible, got %v", err)
+	}
+	if want := "irreducible control flow graph: entries [2 3]"; err.Error() != want {
+		t.Fatalf("expected error %q, got %q", want, err)
+	}
+
+	// Remove the entry 1 -> 3.
+	g.RemoveEdge(g.Node(1), g.Node(3))
+	if !IsReducible(g) {
+		t.Fatal("expected reducible graph")
+	}
+}
+
+func TestMakeReducible(t *testing.T) {
+	// Create the irreducible loop 2 <-> 3, entered at both 2 and 3 from 1.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 3}, {3, 2}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	r, splits, err := MakeReducible(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsReducible(g) {
+		t.Fatal("expected original graph to be unchanged")
+	}
+	if !IsReducible(r) {
+		t.Fatalf("expected reducible graph, got\n%v", r)
+	}
+	// Node 2 is split, and the back edge 3 -> 2 enters its copy.
+	copies := splits[g.Node(2).ID()]
+	if len(splits) != 1 || len(copies) != 1 {
+		t.Fatalf("expected a single copy of node 2, got %v", splits)
+	}
+	split := r.Split(2, copies[0].Idx)
+	if !r.HasEdge(r.Node(3), split) || r.HasEdge(r.Node(3), r.Node(2)) || !r.HasEdge(split, r.Node(3)) {
+		t.Fatalf("expected back edge 3 -> %v, got\n%v", split, r)
+	}
+	if _, err := Structure(r); errors.Is(err, ErrIrreducible) {
+		t.Fatalf("expected no ErrIrreducible, got %v", err)
+	}
+}
+
+func TestLoopExits(t *testing.T) {
+	// Create a while loop with header 2 and latch 5, in which 3 breaks to the
+	// follow 6 and 4 continues at the header.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 6}, {2, 3}, {2, 6}, {3, 4}, {3, 6}, {4, 2}, {4, 5}, {5, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == PreTestedLoop })
+	if i == -1 || prims[i].Exit != 6 {
+		t.Fatalf("expected pre-tested loop with follow 6, got %v", prims)
+	}
+	breaks, continues := LoopExits(g, prims[i])
+	if !slices.Equal(breaks, [][2]int{{3, 6}}) {
+		t.Fatalf("expected breaks [[3 6]], got %v", breaks)
+	}
+	if !slices.Equal(continues, [][2]int{{4, 2}}) {
+		t.Fatalf("expected continues [[4 2]], got %v", continues)
+	}
+}
+
+func TestAbnormalExits(t *testing.T) {
+	// Create an endless loop 2 -> 3 -> 4 -> 5 -> 6 -> 2 with exits from 3, 4
+	// and 5 to 7, 8 and 9 respectively, also reachable from 1.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 7}, {1, 8}, {1, 9}, {2, 3}, {3, 4}, {3, 7}, {4, 5}, {4, 8}, {5, 6}, {5, 9}, {6, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == ConditionalEndlessLoop })
+	if i == -1 {
+		t.Fatalf("expected conditional endless loop, got %v", prims)
+	}
+	// Every exit other than the one to the follow is abnormal.
+	sources := map[int]int{7: 3, 8: 4, 9: 5}
+	var want []int
+	for _, exit := range []int{7, 8, 9} {
+		if exit != prims[i].Exit {
+			want = append(want, sources[exit])
+		}
+	}
+	if len(want) != 2 || !slices.Equal(prims[i].AbnormalExits, want) {
+		t.Fatalf("expected abnormal exits %v, got %v (follow %d)", want, prims[i].AbnormalExits, prims[i].Exit)
+	}
+}
+
+func TestSelfLoop(t *testing.T) {
+	tests := []struct {
+		name   string
+		edges  [][2]int
+		follow int
+		cond   bool
+	}{
+		// do {} while (2); 3
+		{"conditional", [][2]int{{1, 2}, {2, 2}, {2, 3}}, 3, true},
+		// for {}
+		{"unconditional", [][2]int{{1, 2}, {2, 2}}, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New[int]()
+			g.SetRoot(g.Node(1))
+			for _, edge := range test.edges {
+				g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+			}
+			prims, err := Structure(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prims) != 1 || prims[0].Kind != SelfLoop || !slices.Equal(prims[0].Body, []int{2}) {
+				t.Fatalf("expected self-loop with body [2], got %v", prims)
+			}
+			if prims[0].Exit != test.follow {
+				t.Fatalf("expected follow %d, got %d", test.follow, prims[0].Exit)
+			}
+			if _, ok := prims[0].Extra["cond"]; ok != test.cond {
+				t.Fatalf("expected conditional self-loop %t, got %v", test.cond, prims[0].Extra)
+			}
+		})
+	}
+}
+
+func TestLatchConditional(t *testing.T) {
+	// Create a while loop with header 2 whose latch 4 breaks to the follow 5,
+	// i.e. while (2) { 3; if (4) break; }, and a do-while loop with latch 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 5}, {2, 3}, {2, 5}, {3, 4}, {4, 2}, {4, 5}, {5, 6}, {6, 7}, {7, 6}, {7, 8}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(prims, func(prim Primitive[int]) bool {
+		return prim.Kind == TwoWayConditional && prim.Entry == 4
+	})
+	if i == -1 {
+		t.Fatalf("expected conditional at latch 4, got %v", prims)
+	}
+	if prims[i].Exit != 2 || prims[i].Extra["break"] != 5 {
+		t.Fatalf("expected follow 2 and break 5, got %v", prims[i])
+	}
+	if prims[i].Parent == nil || prims[i].Parent.Kind != PreTestedLoop || prims[i].Parent.Entry != 2 {
+		t.Fatalf("expected conditional nested in pre-tested loop 2, got %v", prims[i].Parent)
+	}
+	// The latch of the do-while loop controls the loop.
+	if slices.ContainsFunc(prims, func(prim Primitive[int]) bool { return prim.Kind == TwoWayConditional && prim.Entry == 7 }) {
+		t.Fatalf("expected no conditional at latch 7, got %v", prims)
+	}
+}
+
+func TestSingleBranchFollow(t *testing.T) {
+	tests := []struct {
+		name   string
+		edges  [][2]int
+		cond   int
+		follow int
+	}{
+		// if (1) { 2; return 3 } if (4) { 5 } 6
+		{"sink path", [][2]int{{1, 2}, {1, 4}, {2, 3}, {4, 5}, {4, 6}, {5, 6}}, 1, 4},
+		// The merge node 5 of the conditional 2 is also entered from 1.
+		{"post-dominator", [][2]int{{1, 2}, {1, 5}, {2, 3}, {2, 4}, {3, 5}, {4, 5}}, 2, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New[int]()
+			g.SetRoot(g.Node(1))
+			for _, edge := range test.edges {
+				g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+			}
+			prims, err := Structure(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := slices.IndexFunc(prims, func(prim Primitive[int]) bool {
+				return prim.Kind == TwoWayConditional && prim.Entry == test.cond
+			})
+			if i == -1 || prims[i].Exit != test.follow {
+				t.Fatalf("expected conditional %d with follow %d, got %v", test.cond, test.follow, prims)
+			}
+		})
+	}
+}
+
+func TestStructureContext(t *testing.T) {
+	// Create the loop 2 <-> 3 after the entry 1, exited from 2 to 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {2, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := StructureContext(ctx, g); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	prims, err := StructureContext(context.Background(), g)
+	if err != nil || !slices.ContainsFunc(prims, func(prim Primitive[int]) bool {
+		return prim.Kind == PreTestedLoop && prim.Entry == 2
+	}) {
+		t.Fatalf("expected pre-tested loop at 2, got %v, %v", prims, err)
+	}
+}
+
+func TestPrimitiveJSON(t *testing.T) {
+	prim := Primitive[int]{
+		Kind:  PreTestedLoop,
+		Entry: 2,
+		Body:  []int{3, 4},
+		Exit:  5,
+		Extra: map[string]int{"latch": 4, "follow": 5},
+	}
+	data, err := json.Marshal(prim)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"kind":"PreTestedLoop","entry":2,"exit":5,"body":[3,4],"extra":{"follow":5,"latch":4}}`
+	if string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+	var got Primitive[int]
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, prim) {
+		t.Fatalf("expected %v, got %v", prim, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"kind":"Bogus"}`), &got); err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+}
+
+func TestPrimitiveString(t *testing.T) {
+	prim := Primitive[int]{
+		Kind:  PreTestedLoop,
+		Entry: 2,
+		Body:  []int{3, 4},
+		Exit:  5,
+		Extra: map[string]int{"latch": 4, "follow": 5},
+	}
+	want := "PreTestedLoop(entry=2, follow=5, latch=4, body=[3 4])"
+	if s := prim.String(); s != want {
+		t.Fatalf("expected %s, got %s", want, s)
+	}
+	prim = Primitive[int]{Kind: GuardLadder, Entry: 1, Body: []int{1, 2}, Exit: 3}
+	want = "GuardLadder(entry=1, exit=3, body=[1 2])"
+	if s := prim.String(); s != want {
+		t.Fatalf("expected %s, got %s", want, s)
+	}
+}
+
+func TestEmit(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges [][2]int
+		want  string
+	}{
+		{
+			// A while loop 2 with the diamond 3 -> {4, 5} -> 6, followed by the
+			// do-while loop 8 <-> 9 and the switch 10 over 11, 12 and 13.
+			"nested",
+			[][2]int{
+				{1, 2}, {2, 7}, {2, 3}, {3, 4}, {3, 5}, {4, 6}, {5, 6}, {6, 2},
+				{7, 8}, {8, 9}, {9, 8}, {9, 10},
+				{10, 11}, {10, 12}, {10, 13}, {11, 13}, {12, 13},
+			},
+			`b1
+while (b2) {
+    if (b3) {
+        b4
+    } else {
+        b5
+    }
+    b6
+}
+b7
+do {
+    b8
+} while (b9);
+switch (b10) {
+case 0:
+    b12
+    break;
+case 1:
+    b11
+    break;
+default:
+    break;
+}
+b13
+`,
+		},
+		{
+			// A while loop 2 left from 3 through 4 to its follow 5.
+			"break",
+			[][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}, {2, 5}, {4, 5}},
+			`b1
+while (b2) {
+    if (b3) {
+        b4
+        break;
+    }
+}
+b5
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := graph.New[int]()
+			g.SetRoot(g.Node(1))
+			for _, edge := range test.edges {
+				g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+			}
+			prims, err := Structure(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := Emit(g, BuildPrimitiveTree(prims), func(v int) string {
+				return fmt.Sprintf("b%d", v)
+			})
+			if code != test.want {
+				t.Fatalf("expected\n%s\ngot\n%s", test.want, code)
+			}
+		})
+	}
+}
+
+// recordingVisitor records the category and entry of each visited primitive,
+// and fails at the given entry.
+type recordingVisitor struct {
+	visits []string
+	fail   int
+}
+
+func (v *recordingVisitor) record(category string, prim Primitive[int]) error {
+	v.visits = append(v.visits, fmt.Sprintf("%s %d", category, prim.Entry))
+	if prim.Entry == v.fail {
+		return errors.New("fail")
+	}
+	return nil
+}
+
+func (v *recordingVisitor) VisitLoop(prim Primitive[int]) error {
+	return v.record("loop", prim)
+}
+
+func (v *recordingVisitor) VisitConditional(prim Primitive[int]) error {
+	return v.record("cond", prim)
+}
+
+func (v *recordingVisitor) VisitNWay(prim Primitive[int]) error {
+	return v.record("nway", prim)
+}
+
+func (v *recordingVisitor) VisitOther(prim Primitive[int]) error {
+	return v.record("other", prim)
+}
+
+func TestWalk(t *testing.T) {
+	prims := []Primitive[int]{
+		{Kind: PreTestedLoop, Entry: 2, Body: []int{2, 3, 4, 6}, Extra: map[string]int{"latch": 6, "follow": 7}},
+		{Kind: NWayConditional, Entry: 3, Body: []int{4}, Extra: map[string]int{"cond": 3}},
+		{Kind: TwoWayConditional, Entry: 4, Extra: map[string]int{"cond": 4, "follow": 6}},
+		{Kind: GuardLadder, Entry: 8, Body: []int{8}},
+	}
+	tree := BuildPrimitiveTree(prims)
+
+	v := &recordingVisitor{}
+	if err := Walk(tree, v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"loop 2", "nway 3", "cond 4", "other 8"}
+	if !slices.Equal(v.visits, want) {
+		t.Fatalf("expected %v, got %v", want, v.visits)
+	}
+
+	// An error aborts the walk.
+	v = &recordingVisitor{fail: 3}
+	if err := Walk(tree, v); err == nil || !slices.Equal(v.visits, want[:2]) {
+		t.Fatalf("expected walk aborted after %v, got %v, %v", want[:2], v.visits, err)
+	}
+}
+
+func TestLoopFollowFailure(t *testing.T) {
+	for _, test := range []struct {
+		edges [][2]int
+		kind  PrimitiveKind
+		err   error
+	}{
+		// The loop 2 <-> 3 has no exit and is truly endless.
+		{[][2]int{{1, 2}, {2, 3}, {3, 2}}, EndlessLoop, nil},
+		// The loop 2 -> 3 -> 4 -> 2 is left by the break from 3 to 5.
+		{[][2]int{{1, 2}, {1, 5}, {2, 3}, {3, 4}, {3, 5}, {4, 2}}, ConditionalEndlessLoop, nil},
+		// The loop 2 -> 3 -> 4 -> 2 is left from 3 to 5, which is dominated by
+		// the header but not part of the natural loop, and thus its follow.
+		{[][2]int{{1, 2}, {1, 7}, {2, 3}, {3, 4}, {3, 5}, {4, 2}, {5, 7}}, ConditionalEndlessLoop, nil},
+	} {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range test.edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+		prims, err := Structure(g)
+		if test.err == nil && err != nil || test.err != nil && !errors.Is(err, test.err) {
+			t.Fatalf("%v: expected error %v, got %v", test.edges, test.err, err)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool { return prim.Entry == 2 })
+		switch {
+		case test.kind == None && i != -1:
+			t.Fatalf("%v: expected no loop at 2, got %v", test.edges, prims[i])
+		case test.kind != None && (i == -1 || prims[i].Kind != test.kind):
+			t.Fatalf("%v: expected %v at 2, got %v", test.edges, test.kind, prims)
+		}
+	}
+}
+
+func TestMultipleLatches(t *testing.T) {
+	for _, test := range []struct {
+		edges [][2]int
+		cond  int
+		extra string
+		want  int
+	}{
+		// while (2) { if (3) { 4 } else { 5 } }, where both arms of 3 branch back
+		// to the header, which is the follow of 3.
+		{[][2]int{{1, 2}, {2, 3}, {2, 6}, {3, 4}, {3, 5}, {4, 2}, {5, 2}}, 3, "loop", 2},
+		// while (2) { 3; if (4) continue; 5; 6 }, where 4 is a secondary latch and
+		// 6 the latch of the loop.
+		{[][2]int{{1, 2}, {2, 3}, {2, 7}, {3, 4}, {3, 5}, {4, 2}, {4, 5}, {5, 6}, {6, 2}}, 4, "continue", 2},
+	} {
+		g := graph.New[int]()
+		g.SetRoot(g.Node(1))
+		for _, edge := range test.edges {
+			g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+		}
+		prims, err := Structure(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loops := slices.DeleteFunc(slices.Clone(prims), func(prim Primitive[int]) bool {
+			return prim.Kind != PreTestedLoop
+		})
+		if len(loops) != 1 || loops[0].Entry != 2 {
+			t.Fatalf("%v: expected a single pre-tested loop at 2, got %v", test.edges, prims)
+		}
+		i := slices.IndexFunc(prims, func(prim Primitive[int]) bool {
+			return prim.Kind == TwoWayConditional && prim.Entry == test.cond
+		})
+		if i == -1 {
+			t.Fatalf("%v: expected conditional at %d, got %v", test.edges, test.cond, prims)
+		}
+		if v, ok := prims[i].Extra[test.extra]; !ok || v != test.want {
+			t.Fatalf("%v: expected %s %d, got %v", test.edges, test.extra, test.want, prims[i])
+		}
+	}
+}
+
+func TestLoopHeaders(t *testing.T) {
+	// Create the loop 2 -> 3 -> 7 -> 2 containing the self-loop 3, and the loop
+	// 4 -> 5 -> 4 after it.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 3}, {3, 7}, {7, 2}, {2, 4}, {4, 5}, {5, 4}, {4, 6}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	var headers []int
+	for _, n := range LoopHeaders(g, dominator.New(g)) {
+		headers = append(headers, n.Value)
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(headers)), []int{2, 3, 4}) {
+		t.Fatalf("expected headers [2 3 4], got %v", headers)
+	}
+
+	// The headers are consistent with the loop headers marked by Structure.
+	if _, err := Structure(g); err != nil {
+		t.Fatal(err)
+	}
+	var marked []int
+	for _, n := range ascReversePostOrder(g.Nodes()) {
+		if n.IsLoopHead {
+			marked = append(marked, n.Value)
+		}
+	}
+	if !slices.Equal(marked, headers) {
+		t.Fatalf("expected marked headers %v, got %v", headers, marked)
+	}
+}
+
+func TestUnmappedLatch(t *testing.T) {
+	// Create a graph whose loop latch in the derived sequence of graphs, the
+	// interval I(4), has no counterpart in the original graph, which used to
+	// panic. The self-loop 6 is still structured.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {3, 5}, {2, 6}, {6, 2}, {6, 6}, {4, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	prims, err := Structure(g)
+	if err == nil {
+		t.Fatal("expected error for unmapped latch")
+	}
+	if !slices.ContainsFunc(prims, func(prim Primitive[int]) bool {
+		return prim.Kind == SelfLoop && prim.Entry == 6
+	}) {
+		t.Fatalf("expected self-loop at 6, got %v", prims)
+	}
+}
+
+func TestReversePostOrder(t *testing.T) {
+	// Create the chain 1 -> 2 -> 3.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+	g.SetEdge(g.Node(2), g.Node(3))
+	g.InitOrder()
+
+	nodes := []*graph.Node[int]{g.Node(2), g.Node(3), g.Node(1)}
+	values := func(nodes []*graph.Node[int]) []int {
+		var vs []int
+		for _, n := range nodes {
+			vs = append(vs, n.Value)
+		}
+		return vs
+	}
+	if asc := values(ascReversePostOrder(nodes)); !slices.Equal(asc, []int{1, 2, 3}) {
+		t.Fatalf("expected ascending order [1 2 3], got %v", asc)
+	}
+	if desc := values(descReversePostOrder(nodes)); !slices.Equal(desc, []int{3, 2, 1}) {
+		t.Fatalf("expected descending order [3 2 1], got %v", desc)
+	}
+	// The given slice is left unchanged.
+	if vs := values(nodes); !slices.Equal(vs, []int{2, 3, 1}) {
+		t.Fatalf("expected unchanged nodes [2 3 1], got %v", vs)
+	}
+
+	// Orders whose difference overflows are sorted correctly.
+	g.Node(1).Order, g.Node(2).Order = math.MinInt, math.MaxInt
+	if asc := values(ascReversePostOrder(nodes)); !slices.Equal(asc, []int{1, 3, 2}) {
+		t.Fatalf("expected ascending order [1 3 2], got %v", asc)
+	}
+}
+
+func TestCoalesceLinearChains(t *testing.T) {
+	// Create the loop 2 -> 3 -> 4 -> 2 after the entry 1, left from 2 to the
+	// chain 5 -> 6 -> 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}, {2, 5}, {5, 6}, {6, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	// The chains 3 -> 4 and 5 -> 6 -> 7 are merged, but not the latch 4 into
+	// the header 2, nor 1 into 2, which has several predecessors.
+	if merges := CoalesceLinearChains(g); merges != 3 {
+		t.Fatalf("expected 3 merges, got %d", merges)
+	}
+	var edges [][2]int
+	for from, to := range g.Edges() {
+		edges = append(edges, [2]int{from.Value, to.Value})
+	}
+	if !slices.Equal(edges, [][2]int{{1, 2}, {2, 3}, {2, 5}, {3, 2}}) {
+		t.Fatalf("expected edges [[1 2] [2 3] [2 5] [3 2]], got %v", edges)
+	}
+
+	// The loop 1 -> 2 -> 3 -> 1 at the root shrinks to 1 -> 3 -> 1, rather
+	// than losing its back edge.
+	g = graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if merges := CoalesceLinearChains(g); merges != 1 || !g.HasEdge(g.Node(3), g.Root()) {
+		t.Fatalf("expected the loop 1 -> 3 -> 1, got %d merges and\n%v", merges, g)
+	}
+}
+
+func TestDebugReport(t *testing.T) {
+	// Create the loop 2 <-> 3 after the entry 1, exited from 2 to 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {2, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	report := DebugReport(g)
+	if !strings.Contains(report, "PreTestedLoop 2: body [2 3]") {
+		t.Fatalf("expected pre-tested loop 2 in report, got\n%s", report)
+	}
+	// A report of an already structured graph is the same.
+	if _, err := Structure(g); err != nil {
+		t.Fatal(err)
+	}
+	if again := DebugReport(g); again != report {
+		t.Fatalf("expected report of structured graph\n%s\ngot\n%s", report, again)
+	}
+}
+
+func TestReRootRestructure(t *testing.T) {
+	// Create the loop 2 <-> 3 after the entry 1, exited from 2 to 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {2, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	first, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-rooting clears the loop state of the previous run, such that the
+	// graph structures as before.
+	g.ReRoot(g.Root())
+	second, err := Structure(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Fatalf("expected %v after re-rooting, got %v", first, second)
+	}
+	if !g.Node(2).IsLoopHead || !g.Node(3).IsLoopLatch {
+		t.Fatal("expected loop state to be recomputed")
+	}
+}
@@ -0,0 +1,81 @@
+// Package decompiletest provides utilities for testing the structuring of
+// control flow graphs.
+package decompiletest
+
+import (
+	"testing"
+
+	"github.com/nukilabs/decompile"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// ExpectedPrimitive describes a primitive expected to be produced by
+// structuring a control flow graph.
+type ExpectedPrimitive[N comparable] struct {
+	// Kind of the primitive.
+	Kind decompile.PrimitiveKind
+	// Entry node of the primitive.
+	Entry N
+	// Body of the primitive, compared as a set.
+	Body []N
+}
+
+// AssertStructure structures the given control flow graph and reports each
+// mismatch against the expected primitives: missing primitives, primitives
+// with differing bodies and unexpected primitives. Primitives are matched by
+// kind and entry node, irrespective of their order.
+func AssertStructure[N comparable](t testing.TB, g *graph.Graph[N], expected []ExpectedPrimitive[N]) {
+	t.Helper()
+	prims, err := decompile.Structure(g)
+	if err != nil {
+		t.Errorf("unable to structure graph: %v", err)
+	}
+	matched := make([]bool, len(prims))
+	for _, exp := range expected {
+		idx := -1
+		for i, prim := range prims {
+			if !matched[i] && prim.Kind == exp.Kind && prim.Entry == exp.Entry {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			t.Errorf("missing %v primitive with entry %v", exp.Kind, exp.Entry)
+			continue
+		}
+		matched[idx] = true
+		missing, unexpected := diff(exp.Body, prims[idx].Body)
+		if len(missing) > 0 || len(unexpected) > 0 {
+			t.Errorf("%v primitive with entry %v: body is missing %v and has unexpected %v", exp.Kind, exp.Entry, missing, unexpected)
+		}
+	}
+	for i, prim := range prims {
+		if !matched[i] {
+			t.Errorf("unexpected %v primitive with entry %v", prim.Kind, prim.Entry)
+		}
+	}
+}
+
+// diff returns the values of expected missing from actual, and the values of
+// actual which are not expected.
+func diff[N comparable](expected, actual []N) (missing, unexpected []N) {
+	set := func(values []N) map[N]struct{} {
+		s := make(map[N]struct{}, len(values))
+		for _, v := range values {
+			s[v] = struct{}{}
+		}
+		return s
+	}
+	exp, act := set(expected), set(actual)
+	for _, v := range expected {
+		if _, ok := act[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	for _, v := range actual {
+		if _, ok := exp[v]; !ok {
+			unexpected = append(unexpected, v)
+		}
+	}
+	return missing, unexpected
+}
@@ -0,0 +1,23 @@
+package decompiletest
+
+import (
+	"testing"
+
+	"github.com/nukilabs/decompile"
+	"github.com/nukilabs/decompile/graph"
+)
+
+func TestAssertStructure(t *testing.T) {
+	// Create a post-tested loop with header 2 and latch 3, followed by a
+	// conditional 4 with arms 5 and 6 merging at 7.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 5}, {4, 6}, {5, 7}, {6, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	AssertStructure(t, g, []ExpectedPrimitive[int]{
+		{Kind: decompile.PostTestedLoop, Entry: 2, Body: []int{2, 3}},
+		{Kind: decompile.TwoWayConditional, Entry: 4},
+	})
+}
@@ -1,12 +1,23 @@
 package dominator
 
-import "github.com/nukilabs/decompile/graph"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nukilabs/decompile/graph"
+)
 
 // Tree represents the dominator tree of a directed graph.
 type Tree[N comparable] struct {
+	graph       *graph.Graph[N]
 	root        *graph.Node[N]
 	dominatorOf map[graph.ID[N]]*graph.Node[N]
 	dominatedBy map[graph.ID[N]][]*graph.Node[N]
+	// preds returns the predecessors of a node along the edges the tree was
+	// built from, i.e. the successors in the graph for post-dominator trees.
+	preds func(n *graph.Node[N]) []*graph.Node[N]
 }
 
 // Root returns the entry (root) node of the dominator tree.
@@ -14,7 +25,8 @@ func (dt *Tree[N]) Root() *graph.Node[N] {
 	return dt.root
 }
 
-// DominatorOf returns the immediate dominator of node n.
+// DominatorOf returns the immediate dominator of node n. It returns nil for the
+// root, which has no immediate dominator, and for nodes not in the tree.
 func (dt *Tree[N]) DominatorOf(n *graph.Node[N]) *graph.Node[N] {
 	return dt.dominatorOf[n.ID()]
 }
@@ -24,21 +36,221 @@ func (dt *Tree[N]) DominatedBy(n *graph.Node[N]) []*graph.Node[N] {
 	return dt.dominatedBy[n.ID()]
 }
 
-// Dominates returns true if node a dominates node b.
+// Siblings returns the nodes sharing the immediate dominator of n, excluding n
+// itself, sorted by their order in the graph. The root has no siblings.
+func (dt *Tree[N]) Siblings(n *graph.Node[N]) []*graph.Node[N] {
+	dom := dt.DominatorOf(n)
+	if dom == nil {
+		return nil
+	}
+	var siblings []*graph.Node[N]
+	for _, sibling := range dt.DominatedBy(dom) {
+		if sibling.ID() != n.ID() {
+			siblings = append(siblings, sibling)
+		}
+	}
+	slices.SortFunc(siblings, func(a, b *graph.Node[N]) int {
+		return cmp.Compare(a.Order, b.Order)
+	})
+	return siblings
+}
+
+// Dominates returns true if node a dominates node b, i.e. if a is on the
+// chain of dominators of b. Every node dominates itself.
+//
+// Dominance is transitive: a loop header dominates its latches through the
+// nodes of the loop body, so the back edges of a loop are only found by
+// walking the whole chain. Dominates used to report immediate dominance only,
+// which missed every latch not immediately dominated by its header; use
+// ImmediatelyDominates for that check.
 func (dt *Tree[N]) Dominates(a, b *graph.Node[N]) bool {
+	for dom := b; dom != nil; dom = dt.DominatorOf(dom) {
+		if dom.ID() == a.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// ImmediatelyDominates returns true if node a is the immediate dominator of
+// node b.
+func (dt *Tree[N]) ImmediatelyDominates(a, b *graph.Node[N]) bool {
 	dom := dt.DominatorOf(b)
 	return dom != nil && dom.ID() == a.ID()
 }
 
+// PathToRoot returns the path from node n up to the root of the dominator tree,
+// i.e. n, its immediate dominator, the immediate dominator thereof, and so on
+// up to and including the root. It returns nil if n is not in the tree.
+func (dt *Tree[N]) PathToRoot(n *graph.Node[N]) []*graph.Node[N] {
+	if n.ID() != dt.root.ID() && dt.DominatorOf(n) == nil {
+		return nil
+	}
+	var path []*graph.Node[N]
+	for dom := n; dom != nil; dom = dt.DominatorOf(dom) {
+		path = append(path, dom)
+		if dom.ID() == dt.root.ID() {
+			break
+		}
+	}
+	return path
+}
+
+// IDomChain returns the chain of immediate dominators of node n, starting with
+// n itself and ending with the root inclusive. It is identical to PathToRoot,
+// and returns nil if n is not in the tree.
+func (dt *Tree[N]) IDomChain(n *graph.Node[N]) []*graph.Node[N] {
+	return dt.PathToRoot(n)
+}
+
+// LCA returns the lowest common ancestor of nodes a and b in the dominator
+// tree, i.e. the nearest node dominating both a and b. It returns nil if either
+// node is not in the tree.
+func (dt *Tree[N]) LCA(a, b *graph.Node[N]) *graph.Node[N] {
+	chainA, chainB := dt.IDomChain(a), dt.IDomChain(b)
+	if chainA == nil || chainB == nil {
+		return nil
+	}
+	// Walk up the deeper chain to equal depth, then both chains in lockstep.
+	for len(chainA) > len(chainB) {
+		chainA = chainA[1:]
+	}
+	for len(chainB) > len(chainA) {
+		chainB = chainB[1:]
+	}
+	for i := range chainA {
+		if chainA[i].ID() == chainB[i].ID() {
+			return chainA[i]
+		}
+	}
+	return nil
+}
+
+// Frontier returns the dominance frontier of node n, i.e. the nodes which have
+// a predecessor dominated by n but which are not strictly dominated by n
+// themselves, sorted by their order in the graph. It is computed by the
+// algorithm of Cytron et al. over the edges the tree was built from, such that
+// the frontier of a post-dominator tree is the post-dominance frontier.
+func (dt *Tree[N]) Frontier(n *graph.Node[N]) []*graph.Node[N] {
+	var frontier []*graph.Node[N]
+	seen := make(map[graph.ID[N]]struct{})
+	for _, b := range dt.nodes() {
+		preds := dt.preds(b)
+		if len(preds) < 2 {
+			continue
+		}
+		idom := dt.DominatorOf(b)
+		for _, p := range preds {
+			// Walk up from each predecessor to the immediate dominator of b;
+			// b is in the frontier of every node passed on the way.
+			for runner := p; runner != nil && (idom == nil || runner.ID() != idom.ID()); runner = dt.DominatorOf(runner) {
+				if runner.ID() != dt.root.ID() && dt.DominatorOf(runner) == nil {
+					// Predecessor not in the tree.
+					break
+				}
+				if runner.ID() == n.ID() {
+					if _, ok := seen[b.ID()]; !ok {
+						seen[b.ID()] = struct{}{}
+						frontier = append(frontier, b)
+					}
+					break
+				}
+			}
+		}
+	}
+	slices.SortFunc(frontier, func(a, b *graph.Node[N]) int {
+		return cmp.Compare(a.Order, b.Order)
+	})
+	return frontier
+}
+
+// nodes returns the nodes of the dominator tree, starting with the root.
+func (dt *Tree[N]) nodes() []*graph.Node[N] {
+	nodes := []*graph.Node[N]{dt.root}
+	for _, dominated := range dt.dominatedBy {
+		nodes = append(nodes, dominated...)
+	}
+	return nodes
+}
+
+// Validate checks the invariants of the dominator tree: the root has no
+// immediate dominator, the chain of dominators of every node reaches the root
+// without cycles, and every node of the graph appears in the tree.
+func (dt *Tree[N]) Validate() error {
+	var errs []error
+	if dt.root == nil {
+		return errors.New("dominator: tree has no root")
+	}
+	if dom := dt.DominatorOf(dt.root); dom != nil && dom.ID() != dt.root.ID() {
+		errs = append(errs, fmt.Errorf("dominator: root %v is dominated by %v", dt.root, dom))
+	}
+	for n := range dt.graph.AllNodes() {
+		if n.ID() == dt.root.ID() {
+			continue
+		}
+		if dt.DominatorOf(n) == nil {
+			errs = append(errs, fmt.Errorf("dominator: node %v does not appear in tree", n))
+			continue
+		}
+		// The chain of dominators is at most as long as the number of nodes in
+		// the tree, unless it contains a cycle.
+		dom := n
+		for i := 0; dom != nil && dom.ID() != dt.root.ID(); i++ {
+			if i > len(dt.dominatorOf) {
+				errs = append(errs, fmt.Errorf("dominator: chain of dominators of node %v contains a cycle", n))
+				break
+			}
+			dom = dt.DominatorOf(dom)
+		}
+		if dom == nil {
+			errs = append(errs, fmt.Errorf("dominator: chain of dominators of node %v does not reach root", n))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // New computes the dominator tree for all nodes in the graph
 // using the Lengauer–Tarjan algorithm. The graph's own root (graph.root) is used.
 func New[N comparable](g *graph.Graph[N]) *Tree[N] {
+	return build(g, g.Root(), g.Successors, g.Predecessors)
+}
+
+// NewPostDominator computes the post-dominator tree for all nodes in the graph,
+// i.e. the dominator tree of the reversed graph. The root of the tree is a
+// synthetic exit node (of kind graph.ExitNode) succeeding every sink node of
+// the graph, such that graphs with multiple exits have a single post-dominator
+// tree. Nodes from which no sink is reachable (e.g. within endless loops) do
+// not appear in the tree.
+func NewPostDominator[N comparable](g *graph.Graph[N]) *Tree[N] {
+	exit := &graph.Node[N]{Kind: graph.ExitNode}
+	sinks := g.Sinks()
+	succs := func(n *graph.Node[N]) []*graph.Node[N] {
+		if n.ID() == exit.ID() {
+			return sinks
+		}
+		return g.Predecessors(n)
+	}
+	preds := func(n *graph.Node[N]) []*graph.Node[N] {
+		if n.ID() == exit.ID() {
+			return nil
+		}
+		if g.OutDegree(n) == 0 {
+			return []*graph.Node[N]{exit}
+		}
+		return g.Successors(n)
+	}
+	return build(g, exit, succs, preds)
+}
+
+// build computes the dominator tree of the graph rooted at the given node, with
+// the edges of the graph given by the succs and preds functions.
+func build[N comparable](g *graph.Graph[N], root *graph.Node[N], succs, preds func(n *graph.Node[N]) []*graph.Node[N]) *Tree[N] {
 	lt := lengauerTarjan[N]{
 		indexOf: make(map[graph.ID[N]]int),
 	}
 
 	// step 1.
-	lt.dfs(g, g.Root())
+	lt.dfs(succs, root)
 
 	for i := len(lt.nodes) - 1; i > 0; i-- {
 		w := lt.nodes[i]
@@ -84,9 +296,11 @@ func New[N comparable](g *graph.Graph[N]) *Tree[N] {
 		dominatedBy[did] = append(dominatedBy[did], w.node)
 	}
 	return &Tree[N]{
-		root:        g.Root(),
+		graph:       g,
+		root:        root,
 		dominatorOf: dominatorOf,
 		dominatedBy: dominatedBy,
+		preds:       preds,
 	}
 }
 
@@ -154,7 +368,7 @@ type ltNode[N comparable] struct {
 }
 
 // dfs is the Lengauer-Tarjan DFS procedure.
-func (lt *lengauerTarjan[N]) dfs(g *graph.Graph[N], v *graph.Node[N]) {
+func (lt *lengauerTarjan[N]) dfs(succs func(n *graph.Node[N]) []*graph.Node[N], v *graph.Node[N]) {
 	i := len(lt.nodes)
 	lt.indexOf[v.ID()] = i
 	ltv := &ltNode[N]{
@@ -165,11 +379,11 @@ func (lt *lengauerTarjan[N]) dfs(g *graph.Graph[N], v *graph.Node[N]) {
 	ltv.label = ltv
 	lt.nodes = append(lt.nodes, ltv)
 
-	for _, w := range g.Successors(v) {
+	for _, w := range succs(v) {
 		wid := w.ID()
 		idx, ok := lt.indexOf[wid]
 		if !ok {
-			lt.dfs(g, w)
+			lt.dfs(succs, w)
 
 			// We place this below the recursive call
 			// in contrast to the original algorithm
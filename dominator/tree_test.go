@@ -0,0 +1,241 @@
+package dominator
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+func TestValidate(t *testing.T) {
+	// Create a simple graph with root 1.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	n4 := g.Node(4)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n1, n3)
+	g.SetEdge(n2, n4)
+	g.SetEdge(n3, n4)
+	g.SetEdge(n4, n1)
+
+	if err := New(g).Validate(); err != nil {
+		t.Fatalf("expected valid dominator tree, got %v", err)
+	}
+
+	// Add a node unreachable from the root.
+	g.SetEdge(g.Node(5), n4)
+	if err := New(g).Validate(); err == nil {
+		t.Fatal("expected error for node missing from dominator tree")
+	}
+}
+
+func TestDominates(t *testing.T) {
+	// Create a chain 1 -> 2 -> 3 with the branch 1 -> 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {1, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	dom := New(g)
+	for _, test := range []struct {
+		a, b      int
+		dominates bool
+		immediate bool
+	}{
+		{1, 2, true, true},
+		{1, 3, true, false},
+		{2, 3, true, true},
+		{3, 3, true, false},
+		{3, 2, false, false},
+		{2, 4, false, false},
+	} {
+		a, b := g.Node(test.a), g.Node(test.b)
+		if got := dom.Dominates(a, b); got != test.dominates {
+			t.Errorf("Dominates(%d, %d) = %v, expected %v", test.a, test.b, got, test.dominates)
+		}
+		if got := dom.ImmediatelyDominates(a, b); got != test.immediate {
+			t.Errorf("ImmediatelyDominates(%d, %d) = %v, expected %v", test.a, test.b, got, test.immediate)
+		}
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	// Create the branches 1 -> {2, 3, 4} with the chain 2 -> 5, such that the
+	// reverse postorder is 1, 4, 3, 2, 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {1, 4}, {2, 5}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	dom := New(g)
+	for _, test := range []struct {
+		n        int
+		siblings []int
+	}{
+		{1, nil},
+		{2, []int{4, 3}},
+		{3, []int{4, 2}},
+		{5, nil},
+	} {
+		var siblings []int
+		for _, sibling := range dom.Siblings(g.Node(test.n)) {
+			siblings = append(siblings, sibling.Value)
+		}
+		if !slices.Equal(siblings, test.siblings) {
+			t.Errorf("Siblings(%d) = %v, expected %v", test.n, siblings, test.siblings)
+		}
+	}
+}
+
+func TestPathToRoot(t *testing.T) {
+	// Create a chain 1 -> 2 -> 3 with a shortcut 1 -> 3.
+	g := graph.New[int]()
+	n1 := g.Node(1)
+	g.SetRoot(n1)
+	n2 := g.Node(2)
+	n3 := g.Node(3)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n2, n3)
+	g.SetEdge(n1, n3)
+
+	dom := New(g)
+	var path []int
+	for _, n := range dom.PathToRoot(n3) {
+		path = append(path, n.Value)
+	}
+	if len(path) != 2 || path[0] != 3 || path[1] != 1 {
+		t.Fatalf("expected path [3 1], got %v", path)
+	}
+	if path := dom.PathToRoot(g.Node(4)); path != nil {
+		t.Fatalf("expected no path for node outside tree, got %v", path)
+	}
+}
+
+func TestNewPostDominator(t *testing.T) {
+	// Create a diamond 1 -> {2, 3} -> 4 with exits 5 and 6 after 4, and an
+	// endless loop 7 <-> 8 entered from 2.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}, {4, 5}, {4, 6}, {2, 7}, {7, 8}, {8, 7}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	pdom := NewPostDominator(g)
+	if root := pdom.Root(); root.Kind != graph.ExitNode {
+		t.Fatalf("expected synthetic exit root, got %v", root)
+	}
+	for _, want := range [][2]int{{1, 4}, {2, 4}, {3, 4}} {
+		if ipdom := pdom.DominatorOf(g.Node(want[0])); ipdom == nil || ipdom.Value != want[1] {
+			t.Errorf("expected immediate post-dominator of %d to be %d, got %v", want[0], want[1], ipdom)
+		}
+	}
+	for _, n := range []int{4, 5, 6} {
+		if ipdom := pdom.DominatorOf(g.Node(n)); ipdom == nil || ipdom.Kind != graph.ExitNode {
+			t.Errorf("expected immediate post-dominator of %d to be the exit, got %v", n, ipdom)
+		}
+	}
+	if ipdom := pdom.DominatorOf(g.Node(7)); ipdom != nil {
+		t.Errorf("expected node in endless loop to be missing from tree, got %v", ipdom)
+	}
+}
+
+func TestFrontier(t *testing.T) {
+	// Create a loop 2 -> {3, 4} -> 5 -> 2 between entry 1 and exit 6, in
+	// which the branch node 2 splits into the arms 3 and 4 merging at 5.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {3, 5}, {4, 5}, {5, 2}, {5, 6}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+
+	dom := New(g)
+	tests := []struct {
+		node     int
+		frontier []int
+	}{
+		{1, nil},
+		{2, []int{2}},
+		{3, []int{5}},
+		{4, []int{5}},
+		{5, []int{2}},
+		{6, nil},
+	}
+	for _, test := range tests {
+		var frontier []int
+		for _, n := range dom.Frontier(g.Node(test.node)) {
+			frontier = append(frontier, n.Value)
+		}
+		if !slices.Equal(frontier, test.frontier) {
+			t.Errorf("expected frontier of %d to be %v, got %v", test.node, test.frontier, frontier)
+		}
+	}
+
+	// The follow 5 of the branch node 2 post-dominates it, and 2 is in the
+	// post-dominance frontier of both arms.
+	pdom := NewPostDominator(g)
+	for _, arm := range []int{3, 4} {
+		var frontier []int
+		for _, n := range pdom.Frontier(g.Node(arm)) {
+			frontier = append(frontier, n.Value)
+		}
+		if !slices.Equal(frontier, []int{2}) {
+			t.Errorf("expected post-dominance frontier of %d to be [2], got %v", arm, frontier)
+		}
+	}
+}
+
+func TestIDomChain(t *testing.T) {
+	// Create a chain 1 -> 2 -> 3 -> 4 with a shortcut 2 -> 4.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {2, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	dom := New(g)
+	if idom := dom.DominatorOf(g.Root()); idom != nil {
+		t.Fatalf("expected no immediate dominator of root, got %v", idom)
+	}
+	var chain []int
+	for _, n := range dom.IDomChain(g.Node(4)) {
+		chain = append(chain, n.Value)
+	}
+	if !slices.Equal(chain, []int{4, 2, 1}) {
+		t.Fatalf("expected chain [4 2 1], got %v", chain)
+	}
+}
+
+func TestLCA(t *testing.T) {
+	// Create a diamond 2 -> {3, 4} -> 5 after entry 1, with 4 branching to 6.
+	g := graph.New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {3, 5}, {4, 5}, {4, 6}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	dom := New(g)
+	tests := []struct {
+		a, b, lca int
+	}{
+		{3, 4, 2},
+		{5, 6, 2},
+		{6, 4, 4},
+		{6, 6, 6},
+		{1, 5, 1},
+	}
+	for _, test := range tests {
+		if lca := dom.LCA(g.Node(test.a), g.Node(test.b)); lca == nil || lca.Value != test.lca {
+			t.Errorf("expected LCA of %d and %d to be %d, got %v", test.a, test.b, test.lca, lca)
+		}
+	}
+	if lca := dom.LCA(g.Node(3), g.Node(7)); lca != nil {
+		t.Errorf("expected no LCA for node outside tree, got %v", lca)
+	}
+}
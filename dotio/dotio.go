@@ -0,0 +1,293 @@
+// Package dotio reads control flow graphs from Graphviz DOT files.
+package dotio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+// ParseDOT parses a directed graph in a simple subset of the DOT language, such
+// as
+//
+//	digraph {
+//		root=a
+//		a -> b -> c
+//		a -> c [label="taken"]
+//	}
+//
+// into a control flow graph with the node names as values. Node, edge and
+// attribute statements are supported, while subgraphs are not. Attributes of
+// nodes and edges are ignored. The root of the graph is the node named by the
+// root graph attribute, if any, and otherwise the first declared node.
+func ParseDOT(r io.Reader) (*graph.Graph[string], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := tokenize(string(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, g: graph.New[string]()}
+	if err := p.parseGraph(); err != nil {
+		return nil, err
+	}
+	if p.first == nil {
+		return nil, errors.New("dotio: graph has no nodes")
+	}
+	root := p.first
+	if p.root != nil {
+		node, ok := p.g.GetNode(*p.root)
+		if !ok {
+			return nil, fmt.Errorf("dotio: root %q is not a node", *p.root)
+		}
+		root = node
+	}
+	p.g.SetRoot(root)
+	return p.g, nil
+}
+
+// token is a lexical token of the DOT language.
+type token struct {
+	// Text of the token; the unquoted string of quoted identifiers.
+	text string
+	// Whether the token is an identifier (as opposed to punctuation).
+	id bool
+	// Line of the token.
+	line int
+}
+
+// tokenize splits the given DOT source into tokens, skipping comments.
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("dotio: line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case strings.HasPrefix(src[i:], "->"):
+			toks = append(toks, token{text: "->", line: line})
+			i += 2
+		case strings.ContainsRune("{}[]=;,", rune(c)):
+			toks = append(toks, token{text: string(c), line: line})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			start := line
+			for i++; ; i++ {
+				if i >= len(src) {
+					return nil, fmt.Errorf("dotio: line %d: unterminated string", start)
+				}
+				if src[i] == '"' {
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '"' {
+					i++
+				}
+				if src[i] == '\n' {
+					line++
+				}
+				sb.WriteByte(src[i])
+			}
+			toks = append(toks, token{text: sb.String(), id: true, line: start})
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] >= 0x80 || (src[j] == '-' && j == i)) {
+				j++
+			}
+			toks = append(toks, token{text: src[i:j], id: true, line: line})
+			i = j
+		default:
+			return nil, fmt.Errorf("dotio: line %d: unexpected character %q", line, c)
+		}
+	}
+	return toks, nil
+}
+
+// parser is a recursive descent parser of DOT tokens.
+type parser struct {
+	toks []token
+	pos  int
+	g    *graph.Graph[string]
+	// First declared node.
+	first *graph.Node[string]
+	// Name of the root node given by the root attribute; nil if not given.
+	root *string
+}
+
+// peek returns the current token, and false at the end of input.
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+// accept consumes the current token if it is the given punctuation.
+func (p *parser) accept(text string) bool {
+	if tok, ok := p.peek(); ok && !tok.id && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the given punctuation, or returns an error.
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q", text)
+	}
+	return nil
+}
+
+// ident consumes an identifier, or returns an error.
+func (p *parser) ident() (string, error) {
+	tok, ok := p.peek()
+	if !ok || !tok.id {
+		return "", p.errorf("expected identifier")
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+// errorf returns an error at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	tok, ok := p.peek()
+	if !ok {
+		return fmt.Errorf("dotio: unexpected end of input: "+format, args...)
+	}
+	return fmt.Errorf("dotio: line %d: unexpected %q: "+format, append([]any{tok.line, tok.text}, args...)...)
+}
+
+// node returns the node of the given name, declaring it if necessary.
+func (p *parser) node(name string) *graph.Node[string] {
+	node := p.g.Node(name)
+	if p.first == nil {
+		p.first = node
+	}
+	return node
+}
+
+// parseGraph parses: ["strict"] "digraph" [ID] "{" stmt_list "}".
+func (p *parser) parseGraph() error {
+	if tok, ok := p.peek(); ok && tok.id && strings.EqualFold(tok.text, "strict") {
+		p.pos++
+	}
+	kw, err := p.ident()
+	if err != nil || !strings.EqualFold(kw, "digraph") {
+		return errors.New("dotio: expected digraph")
+	}
+	if tok, ok := p.peek(); ok && tok.id {
+		p.pos++
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.accept("}") {
+		if err := p.parseStmt(); err != nil {
+			return err
+		}
+		p.accept(";")
+	}
+	if _, ok := p.peek(); ok {
+		return p.errorf("expected end of input")
+	}
+	return nil
+}
+
+// parseStmt parses a node, edge or attribute statement.
+func (p *parser) parseStmt() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	switch {
+	case p.accept("="):
+		// Graph attribute statement: ID "=" ID.
+		value, err := p.ident()
+		if err != nil {
+			return err
+		}
+		p.setGraphAttr(name, value)
+		return nil
+	case strings.EqualFold(name, "subgraph"):
+		return errors.New("dotio: subgraphs are not supported")
+	case strings.EqualFold(name, "graph"), strings.EqualFold(name, "node"), strings.EqualFold(name, "edge"):
+		attrs, err := p.parseAttrs()
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(name, "graph") {
+			for _, attr := range attrs {
+				p.setGraphAttr(attr[0], attr[1])
+			}
+		}
+		return nil
+	}
+	// Node or edge statement: ID ("->" ID)* [attr_list].
+	from := p.node(name)
+	for p.accept("->") {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		to := p.node(name)
+		p.g.SetEdge(from, to)
+		from = to
+	}
+	_, err = p.parseAttrs()
+	return err
+}
+
+// parseAttrs parses an optional attribute list: ("[" [a_list] "]")*.
+func (p *parser) parseAttrs() ([][2]string, error) {
+	var attrs [][2]string
+	for p.accept("[") {
+		for !p.accept("]") {
+			key, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, [2]string{key, value})
+			if !p.accept(",") {
+				p.accept(";")
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// setGraphAttr sets the given graph attribute; only root is meaningful.
+func (p *parser) setGraphAttr(key, value string) {
+	if key == "root" {
+		p.root = &value
+	}
+}
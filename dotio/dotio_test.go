@@ -0,0 +1,53 @@
+package dotio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDOT(t *testing.T) {
+	src := `digraph cfg {
+		// The loop b -> c -> b.
+		a -> b -> c [label="loop"];
+		c -> b
+		c -> "exit block"
+		/* Explicit root. */
+		graph [root=a]
+	}`
+	g, err := ParseDOT(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Root() == nil || g.Root().Value != "a" {
+		t.Fatalf("expected root a, got %v", g.Root())
+	}
+	if g.Len() != 4 {
+		t.Fatalf("expected 4 nodes, got %d", g.Len())
+	}
+	c, _ := g.GetNode("c")
+	if len(g.Successors(c)) != 2 {
+		t.Fatalf("expected 2 successors of c, got %v", g.Successors(c))
+	}
+
+	// The first declared node is the root by default.
+	g, err = ParseDOT(strings.NewReader(`digraph { x; y -> x }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Root().Value != "x" {
+		t.Fatalf("expected root x, got %v", g.Root())
+	}
+
+	for _, src := range []string{
+		``,
+		`digraph {}`,
+		`digraph { a -> }`,
+		`digraph { a -> b`,
+		`graph { a -- b }`,
+		`digraph { root=z; a }`,
+	} {
+		if _, err := ParseDOT(strings.NewReader(src)); err == nil {
+			t.Errorf("expected error for %q", src)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package decompile
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+// Emit returns pseudo-C code for the given control flow graph, as structured
+// by the given primitive tree, indented by nesting level. The given render
+// function returns the statement of a node, or its condition if the node
+// controls a loop or conditional.
+//
+// The graph is walked from its root, such that every reachable node is
+// emitted once. Pre-tested loops are emitted as while loops, post-tested loops
+// as do-while loops and endless loops as for (;;), each followed by its follow
+// node. A jump to the follow of the innermost loop from within its body is
+// emitted as a break. 2-way conditionals are emitted as if statements whose
+// then and else branches are the successors of the conditional node in edge
+// order, omitting an empty branch which directly reaches the follow, and n-way
+// conditionals as switch statements with a case for each successor of the
+// header. Nodes which are not the entry of a primitive are emitted as
+// statements; the successors of such a node with several successors are
+// emitted one after the other.
+//
+// Unlike the other consumers of a primitive tree, Emit takes the graph as
+// well: the tree holds only the nodes which structure a primitive, not the
+// branches of a 2-way conditional which merge at its follow, nor the
+// straight-line code between primitives, so neither could be emitted from
+// the tree alone.
+func Emit[N comparable](g *graph.Graph[N], tree *PrimitiveTree[N], render func(N) string) string {
+	e := newEmitter(g, tree, render, g.Successors)
+	e.run()
+	return e.b.String()
+}
+
+// emitter holds the state of Emit and Layout.
+type emitter[N comparable] struct {
+	g *graph.Graph[N]
+	b strings.Builder
+	// render is nil when only the order of the nodes is of interest.
+	render func(N) string
+	// successors returns the successors of a node in the order in which they
+	// are emitted.
+	successors func(n *graph.Node[N]) []*graph.Node[N]
+	// loops and conds hold the loop and conditional primitives by entry.
+	loops map[N]Primitive[N]
+	conds map[N]Primitive[N]
+	// active holds the headers of the loops being emitted.
+	active map[N]bool
+	// emitted holds the nodes already emitted, and order the order in which
+	// they were emitted.
+	emitted map[N]bool
+	order   []N
+}
+
+// newEmitter returns an emitter for the given graph and the primitives of the
+// given tree.
+func newEmitter[N comparable](g *graph.Graph[N], tree *PrimitiveTree[N], render func(N) string, successors func(n *graph.Node[N]) []*graph.Node[N]) *emitter[N] {
+	e := &emitter[N]{
+		g:          g,
+		render:     render,
+		successors: successors,
+		loops:      make(map[N]Primitive[N]),
+		conds:      make(map[N]Primitive[N]),
+		active:     make(map[N]bool),
+		emitted:    make(map[N]bool),
+	}
+	var collect func(t *PrimitiveTree[N])
+	collect = func(t *PrimitiveTree[N]) {
+		for _, child := range t.Children {
+			p := child.Primitive
+			switch p.Kind {
+			case PreTestedLoop, PostTestedLoop, EndlessLoop, ConditionalEndlessLoop, SelfLoop:
+				if _, ok := e.loops[p.Entry]; !ok {
+					e.loops[p.Entry] = p
+				}
+			case TwoWayConditional, NWayConditional:
+				if _, ok := e.conds[p.Entry]; !ok {
+					e.conds[p.Entry] = p
+				}
+			}
+			collect(child)
+		}
+	}
+	collect(tree)
+	return e
+}
+
+// run emits the graph from its root, followed by the reachable nodes not
+// reached through the structure (e.g. the targets of breaks out of a loop
+// other than to its follow) in depth-first order.
+func (e *emitter[N]) run() {
+	root := e.g.Root()
+	if root == nil {
+		return
+	}
+	e.walk(root, 0, nil)
+	e.g.DFS(func(n *graph.Node[N]) {
+		if !e.emitted[n.Value] {
+			e.walk(n, 0, nil)
+		}
+	}, nil)
+}
+
+// line emits a line of code indented by the given nesting level.
+func (e *emitter[N]) line(depth int, format string, args ...any) {
+	if e.render == nil {
+		return
+	}
+	e.b.WriteString(strings.Repeat("    ", depth))
+	fmt.Fprintf(&e.b, format, args...)
+	e.b.WriteString("\n")
+}
+
+// place marks the given node as emitted, and returns its rendering.
+func (e *emitter[N]) place(v N) string {
+	e.emitted[v] = true
+	e.order = append(e.order, v)
+	if e.render == nil {
+		return ""
+	}
+	return e.render(v)
+}
+
+// walk emits the code starting at the given node until a node of stops is
+// reached, whose statement (e.g. a break) is emitted instead, or a node which
+// has already been emitted.
+func (e *emitter[N]) walk(n *graph.Node[N], depth int, stops map[N]string) {
+	for n != nil {
+		if stmt, ok := stops[n.Value]; ok {
+			if stmt != "" {
+				e.line(depth, "%s", stmt)
+			}
+			return
+		}
+		if e.emitted[n.Value] {
+			return
+		}
+		if p, ok := e.loops[n.Value]; ok && !e.active[n.Value] {
+			n = e.loop(n, p, depth, stops)
+			continue
+		}
+		if p, ok := e.conds[n.Value]; ok {
+			n = e.conditional(n, p, depth, stops)
+			continue
+		}
+		e.line(depth, "%s", e.place(n.Value))
+		succs := e.successors(n)
+		if len(succs) == 0 {
+			return
+		}
+		for _, succ := range succs[:len(succs)-1] {
+			e.walk(succ, depth, stops)
+		}
+		n = succs[len(succs)-1]
+	}
+}
+
+// node returns the node of the given value in the graph, or nil.
+func (e *emitter[N]) node(v N) *graph.Node[N] {
+	if n, ok := e.g.GetNode(v); ok {
+		return n
+	}
+	return nil
+}
+
+// follow returns the follow node of the given primitive, or nil.
+func (e *emitter[N]) follow(p Primitive[N]) *graph.Node[N] {
+	if v, ok := p.Extra["follow"]; ok {
+		return e.node(v)
+	}
+	return nil
+}
+
+// loop emits the given loop primitive with header n, and returns its follow.
+func (e *emitter[N]) loop(n *graph.Node[N], p Primitive[N], depth int, stops map[N]string) *graph.Node[N] {
+	e.active[n.Value] = true
+	defer delete(e.active, n.Value)
+	follow := e.follow(p)
+	inner := maps.Clone(stops)
+	if inner == nil {
+		inner = make(map[N]string)
+	}
+	if follow != nil {
+		inner[follow.Value] = "break;"
+	}
+	switch p.Kind {
+	case PreTestedLoop:
+		e.line(depth, "while (%s) {", e.place(n.Value))
+		inner[n.Value] = ""
+		body := bodySet(p)
+		for _, succ := range e.successors(n) {
+			if _, ok := body[succ.Value]; ok && succ != follow && succ != n {
+				e.walk(succ, depth+1, inner)
+				break
+			}
+		}
+		e.line(depth, "}")
+	case PostTestedLoop:
+		latch := n
+		if v, ok := p.Extra["latch"]; ok {
+			latch = e.node(v)
+		}
+		e.line(depth, "do {")
+		inner[latch.Value] = ""
+		e.walk(n, depth+1, inner)
+		e.line(depth, "} while (%s);", e.place(latch.Value))
+	case SelfLoop:
+		if _, ok := p.Extra["cond"]; ok {
+			e.line(depth, "do {")
+			e.line(depth, "} while (%s);", e.place(n.Value))
+		} else {
+			e.line(depth, "for (;;) {")
+			e.line(depth+1, "%s", e.place(n.Value))
+			e.line(depth, "}")
+		}
+	default:
+		e.line(depth, "for (;;) {")
+		e.walk(n, depth+1, inner)
+		e.line(depth, "}")
+	}
+	return follow
+}
+
+// conditional emits the given conditional primitive with entry n, and returns
+// its follow.
+func (e *emitter[N]) conditional(n *graph.Node[N], p Primitive[N], depth int, stops map[N]string) *graph.Node[N] {
+	follow := e.follow(p)
+	inner := maps.Clone(stops)
+	if inner == nil {
+		inner = make(map[N]string)
+	}
+	if follow != nil {
+		inner[follow.Value] = ""
+	}
+	// arm emits the branch to the given successor.
+	arm := func(succ *graph.Node[N]) {
+		if v, ok := p.Extra["continue"]; ok && v == succ.Value {
+			e.line(depth+1, "continue;")
+		} else {
+			e.walk(succ, depth+1, inner)
+		}
+	}
+	if p.Kind == NWayConditional {
+		e.line(depth, "switch (%s) {", e.place(n.Value))
+		def, hasDefault := p.Extra["default"]
+		for i := 0; ; i++ {
+			v, ok := p.Extra[fmt.Sprintf("case%d", i)]
+			if !ok {
+				break
+			}
+			if hasDefault && v == def {
+				e.line(depth, "default:")
+			} else {
+				e.line(depth, "case %d:", i)
+			}
+			if succ := e.node(v); succ != nil {
+				arm(succ)
+			}
+			e.line(depth+1, "break;")
+		}
+		e.line(depth, "}")
+		return follow
+	}
+	arms := make([]*graph.Node[N], 0, 2)
+	for _, succ := range e.successors(n) {
+		if succ != follow {
+			arms = append(arms, succ)
+		}
+	}
+	e.line(depth, "if (%s) {", e.place(n.Value))
+	for i, succ := range arms {
+		if i > 0 {
+			e.line(depth, "} else {")
+		}
+		arm(succ)
+	}
+	e.line(depth, "}")
+	return follow
+}
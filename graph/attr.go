@@ -0,0 +1,30 @@
+package graph
+
+// Attr is a side-table associating values of type T with the nodes of a graph,
+// e.g. the instructions of basic blocks, without constraining the node values
+// to be comparable structs. Values are keyed by the ID of the node, such that
+// nodes of cloned or reversed graphs share their values. The zero value is an
+// empty side-table ready to use. An Attr is not safe for concurrent use.
+type Attr[N comparable, T any] struct {
+	values map[ID[N]]T
+}
+
+// Set associates the value v with node n, replacing any previous value.
+func (a *Attr[N, T]) Set(n *Node[N], v T) {
+	if a.values == nil {
+		a.values = make(map[ID[N]]T)
+	}
+	a.values[n.ID()] = v
+}
+
+// Get returns the value associated with node n. The boolean return value is
+// false if no value is associated with the node.
+func (a *Attr[N, T]) Get(n *Node[N]) (T, bool) {
+	v, ok := a.values[n.ID()]
+	return v, ok
+}
+
+// Delete removes the value associated with node n, if any.
+func (a *Attr[N, T]) Delete(n *Node[N]) {
+	delete(a.values, n.ID())
+}
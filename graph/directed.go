@@ -1,33 +1,171 @@
 package graph
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
 	"strings"
 )
 
 // Graph represents a directed graph.
 type Graph[N comparable] struct {
-	root     *Node[N]
-	nodes    map[ID[N]]*Node[N]
-	incoming map[*Node[N]]map[*Node[N]]struct{}
-	outgoing map[*Node[N]]map[*Node[N]]struct{}
+	root  *Node[N]
+	nodes map[ID[N]]*Node[N]
+	// incoming and outgoing map the edges of the graph to their sequence
+	// numbers, which order the predecessors and successors of each node.
+	incoming map[*Node[N]]map[*Node[N]]int
+	outgoing map[*Node[N]]map[*Node[N]]int
+	weights  map[*Node[N]]map[*Node[N]]float64
+	// key determines the identity of default nodes of keyed graphs.
+	key   func(N) any
+	keyed map[any]*Node[N]
+	// format renders the values of default nodes; nil for the default format.
+	format func(N) string
+	// seq is the sequence number of the next node added to the graph.
+	seq int
+	// edgeSeq is the sequence number of the next edge added to the graph.
+	edgeSeq int
+	// log records the mutations of recording graphs; nil otherwise.
+	log *MutationLog[N]
+	// version is advanced by every mutation of the graph.
+	version uint64
 }
 
 // New creates a new directed graph with a given root node.
 func New[N comparable]() *Graph[N] {
 	return &Graph[N]{
 		nodes:    map[ID[N]]*Node[N]{},
-		incoming: map[*Node[N]]map[*Node[N]]struct{}{},
-		outgoing: map[*Node[N]]map[*Node[N]]struct{}{},
+		incoming: map[*Node[N]]map[*Node[N]]int{},
+		outgoing: map[*Node[N]]map[*Node[N]]int{},
+		weights:  map[*Node[N]]map[*Node[N]]float64{},
 	}
 }
 
+// NewKeyed creates a new directed graph in which the identity of default
+// nodes is determined by the given key function rather than by the equality of
+// their values. Values with the same key denote the same node, which holds the
+// value it was first added with.
+//
+// Note that equal values always have the same key. To keep distinct blocks
+// with equal-looking values (e.g. repeated labels) apart, the values must
+// carry a distinguishing component (e.g. the block address), and the key
+// function may project values onto it to ignore all other components.
+func NewKeyed[N comparable, K comparable](key func(N) K) *Graph[N] {
+	g := New[N]()
+	g.key = func(value N) any { return key(value) }
+	g.keyed = make(map[any]*Node[N])
+	return g
+}
+
+// Clone returns an independent copy of the graph, with new nodes of the same
+// kind, value and index, connected by the same edges with the same weights.
+// The derived state of the nodes (i.e. their order and loop flags) is reset.
+// The clone shares the key function and formatter of the graph, but does not
+// record mutations.
+func (g *Graph[N]) Clone() *Graph[N] {
+	c, clones := g.cloneNodes()
+	for from, to := range g.Edges() {
+		c.SetEdge(clones[from], clones[to])
+		if w, ok := g.EdgeWeight(from, to); ok {
+			c.SetEdgeWeight(clones[from], clones[to], w)
+		}
+	}
+	if g.root != nil {
+		c.root = clones[g.root]
+	}
+	return c
+}
+
+// Reverse returns a new graph with a copy of each node of the graph (as by
+// Clone) and every edge reversed, along with its weight. The root of the
+// reversed graph is not set, since the exit of a control flow graph is not
+// tracked; the caller must call SetRoot (e.g. with the unique exit node)
+// before analyzing the reversed graph.
+func (g *Graph[N]) Reverse() *Graph[N] {
+	r, clones := g.cloneNodes()
+	for from, to := range g.Edges() {
+		r.SetEdge(clones[to], clones[from])
+		if w, ok := g.EdgeWeight(from, to); ok {
+			r.SetEdgeWeight(clones[to], clones[from], w)
+		}
+	}
+	return r
+}
+
+// Subgraph returns a new graph with a copy of each of the given nodes of the
+// graph (as by Clone), connected by the edges of the graph among them, along
+// with their weights. Edges to or from other nodes are dropped, as are given
+// nodes which are not in the graph. The root of the subgraph is the copy of
+// the given root, or nil if the root is not among the given nodes.
+func (g *Graph[N]) Subgraph(nodes []*Node[N], root *Node[N]) *Graph[N] {
+	var own []*Node[N]
+	for _, n := range nodes {
+		if node, ok := g.nodes[n.ID()]; ok && node == n {
+			own = append(own, n)
+		}
+	}
+	c, clones := g.copyNodes(own)
+	for from, to := range g.Edges() {
+		cfrom, ok := clones[from]
+		if !ok {
+			continue
+		}
+		cto, ok := clones[to]
+		if !ok {
+			continue
+		}
+		c.SetEdge(cfrom, cto)
+		if w, ok := g.EdgeWeight(from, to); ok {
+			c.SetEdgeWeight(cfrom, cto, w)
+		}
+	}
+	if root != nil {
+		c.root = clones[root]
+	}
+	return c
+}
+
+// cloneNodes returns a new graph with a copy of each node of the graph, without
+// edges, and the mapping from the nodes of the graph to their copies.
+func (g *Graph[N]) cloneNodes() (*Graph[N], map[*Node[N]]*Node[N]) {
+	return g.copyNodes(g.Nodes())
+}
+
+// copyNodes returns a new graph with a copy of each of the given nodes of the
+// graph, without edges, and the mapping from the given nodes to their copies.
+// The copies are added in the order in which the nodes were added to the
+// graph.
+func (g *Graph[N]) copyNodes(nodes []*Node[N]) (*Graph[N], map[*Node[N]]*Node[N]) {
+	c := New[N]()
+	c.key = g.key
+	if g.keyed != nil {
+		c.keyed = make(map[any]*Node[N])
+	}
+	c.format = g.format
+	clones := make(map[*Node[N]]*Node[N], len(nodes))
+	for _, n := range sortBySeq(slices.Clone(nodes)) {
+		switch n.Kind {
+		case IntervalNode:
+			clones[n] = c.Interval(n.Idx)
+		case SplitNode:
+			clones[n] = c.Split(n.Value, n.Idx)
+		default:
+			clones[n] = c.Node(n.Value)
+		}
+	}
+	return c, clones
+}
+
 // String returns a string representation of the graph.
 func (g *Graph[N]) String() string {
 	var sb strings.Builder
-	for _, node := range g.nodes {
+	for _, node := range sortBySeq(g.Nodes()) {
 		sb.WriteString(node.String())
 		sb.WriteString(" -> ")
-		for _, succ := range g.Successors(node) {
+		for _, succ := range sortBySeq(g.Successors(node)) {
 			sb.WriteString(succ.String())
 			sb.WriteString(" ")
 		}
@@ -36,9 +174,39 @@ func (g *Graph[N]) String() string {
 	return sb.String()
 }
 
+// SetFormatter sets the function used to render the values of default nodes
+// (e.g. as hexadecimal addresses) in all string representations of the graph
+// and its nodes. A nil function restores the default format (i.e. %v).
+func (g *Graph[N]) SetFormatter(f func(N) string) {
+	g.format = f
+}
+
+// Format renders the given node value using the formatter of the graph.
+func (g *Graph[N]) Format(value N) string {
+	if g.format != nil {
+		return g.format(value)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 // SetRoot sets the root node of the graph.
 func (g *Graph[N]) SetRoot(node *Node[N]) {
 	g.root = node
+	g.record(OpSetRoot, node, nil, 0)
+}
+
+// ReRoot sets the root node of the graph and clears all derived node state,
+// such as the order and loop flags of every node. Unlike SetRoot, the graph
+// must be reanalyzed (e.g. by InitOrder) before the derived state is used again.
+func (g *Graph[N]) ReRoot(node *Node[N]) {
+	g.root = node
+	g.record(OpReRoot, node, nil, 0)
+	for _, n := range g.nodes {
+		n.Order = 0
+		n.IsLoopNode = false
+		n.IsLoopHead = false
+		n.IsLoopLatch = false
+	}
 }
 
 // Root returns the root node of the graph.
@@ -48,25 +216,35 @@ func (g *Graph[N]) Root() *Node[N] {
 
 // GetNode returns the node with the given value.
 func (g *Graph[N]) GetNode(value N) (*Node[N], bool) {
+	if g.key != nil {
+		node, ok := g.keyed[g.key(value)]
+		return node, ok
+	}
 	id := ID[N]{Kind: DefaultNode, Value: value}
 	node, ok := g.nodes[id]
 	return node, ok
 }
 
 // Node adds a new node with the given value to the graph.
-// If a node with the same value already exists, it returns the existing node.
+// If a node with the same value (or key, for keyed graphs) already exists, it
+// returns the existing node.
 func (g *Graph[N]) Node(value N) *Node[N] {
-	id := ID[N]{Kind: DefaultNode, Value: value}
-	if node, ok := g.nodes[id]; ok {
+	if node, ok := g.GetNode(value); ok {
 		return node
 	}
 	node := &Node[N]{
 		Kind:  DefaultNode,
 		Value: value,
+		graph: g,
+		seq:   g.nextSeq(),
+	}
+	if g.key != nil {
+		g.keyed[g.key(value)] = node
 	}
 	g.nodes[node.ID()] = node
-	g.incoming[node] = make(map[*Node[N]]struct{})
-	g.outgoing[node] = make(map[*Node[N]]struct{})
+	g.incoming[node] = make(map[*Node[N]]int)
+	g.outgoing[node] = make(map[*Node[N]]int)
+	g.record(OpNode, node, nil, 0)
 	return node
 }
 
@@ -78,26 +256,193 @@ func (g *Graph[N]) Interval(idx int) *Node[N] {
 		return node
 	}
 	node := &Node[N]{
-		Kind: IntervalNode,
-		Idx:  idx,
+		Kind:  IntervalNode,
+		Idx:   idx,
+		graph: g,
+		seq:   g.nextSeq(),
+	}
+	g.nodes[node.ID()] = node
+	g.incoming[node] = make(map[*Node[N]]int)
+	g.outgoing[node] = make(map[*Node[N]]int)
+	g.record(OpInterval, node, nil, 0)
+	return node
+}
+
+// Split adds a new split node, i.e. a copy of the default node with the given
+// value distinguished by the given index, to the graph. If a split node with
+// the same value and index already exists, it returns the existing node.
+func (g *Graph[N]) Split(value N, idx int) *Node[N] {
+	id := ID[N]{Kind: SplitNode, Idx: idx, Value: value}
+	if node, ok := g.nodes[id]; ok {
+		return node
+	}
+	node := &Node[N]{
+		Kind:  SplitNode,
+		Value: value,
+		Idx:   idx,
+		graph: g,
+		seq:   g.nextSeq(),
 	}
 	g.nodes[node.ID()] = node
-	g.incoming[node] = make(map[*Node[N]]struct{})
-	g.outgoing[node] = make(map[*Node[N]]struct{})
+	g.incoming[node] = make(map[*Node[N]]int)
+	g.outgoing[node] = make(map[*Node[N]]int)
+	g.record(OpSplit, node, nil, 0)
 	return node
 }
 
-// SetEdge creates an edge from the "from" node to the "to" node.
+// CompactIntervalIndices returns a mapping from the index of each interval node
+// of the graph to a compact index in the range 0..k-1, where k is the number
+// of interval nodes. The relative order of the indices is preserved. The graph
+// is not modified; see CompactIntervalIndicesInPlace.
+func (g *Graph[N]) CompactIntervalIndices() map[int]int {
+	idxs := make([]int, 0)
+	for id := range g.nodes {
+		if id.Kind == IntervalNode {
+			idxs = append(idxs, id.Idx)
+		}
+	}
+	slices.Sort(idxs)
+	mapping := make(map[int]int, len(idxs))
+	for i, idx := range idxs {
+		mapping[idx] = i
+	}
+	return mapping
+}
+
+// CompactIntervalIndicesInPlace renumbers the interval nodes of the graph into
+// the compact range of indices computed by CompactIntervalIndices, and returns
+// the mapping from old to new indices. Note that indices referring to the
+// intervals of a derived sequence of graphs must be translated accordingly.
+func (g *Graph[N]) CompactIntervalIndicesInPlace() map[int]int {
+	mapping := g.CompactIntervalIndices()
+	nodes := make(map[ID[N]]*Node[N], len(g.nodes))
+	for _, node := range g.nodes {
+		if node.Kind == IntervalNode {
+			node.Idx = mapping[node.Idx]
+		}
+		nodes[node.ID()] = node
+	}
+	g.nodes = nodes
+	g.record(OpCompactIntervalIndices, nil, nil, 0)
+	return mapping
+}
+
+// nextSeq returns the sequence number of a newly added node.
+func (g *Graph[N]) nextSeq() int {
+	seq := g.seq
+	g.seq++
+	return seq
+}
+
+// sortBySeq sorts the given nodes in the order in which they were added to
+// the graph, and returns them.
+func sortBySeq[N comparable](nodes []*Node[N]) []*Node[N] {
+	slices.SortFunc(nodes, func(a, b *Node[N]) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return nodes
+}
+
+// SetEdge creates an edge from the "from" node to the "to" node. Setting an
+// existing edge has no effect on the order of successors and predecessors.
 func (g *Graph[N]) SetEdge(from, to *Node[N]) {
+	g.record(OpSetEdge, from, to, 0)
+	if g.HasEdge(from, to) {
+		return
+	}
+	seq := g.edgeSeq
+	g.edgeSeq++
+
 	if _, ok := g.outgoing[from]; !ok {
-		g.outgoing[from] = make(map[*Node[N]]struct{})
+		g.outgoing[from] = make(map[*Node[N]]int)
 	}
-	g.outgoing[from][to] = struct{}{}
+	g.outgoing[from][to] = seq
 
 	if _, ok := g.incoming[to]; !ok {
-		g.incoming[to] = make(map[*Node[N]]struct{})
+		g.incoming[to] = make(map[*Node[N]]int)
+	}
+	g.incoming[to][from] = seq
+}
+
+// RemoveEdge removes the edge from the "from" node to the "to" node, along with
+// its weight, leaving both nodes in the graph. Removing an edge which does not
+// exist, or between nodes not in the graph, has no effect.
+func (g *Graph[N]) RemoveEdge(from, to *Node[N]) {
+	g.record(OpRemoveEdge, from, to, 0)
+	delete(g.outgoing[from], to)
+	delete(g.incoming[to], from)
+	delete(g.weights[from], to)
+}
+
+// RemoveNode removes the given node from the graph, along with its incoming and
+// outgoing edges and their weights. If the node is the root of the graph, the
+// root is set to nil. Removing a node which is not in the graph has no effect.
+func (g *Graph[N]) RemoveNode(n *Node[N]) {
+	if node, ok := g.nodes[n.ID()]; !ok || node != n {
+		return
+	}
+	g.record(OpRemoveNode, n, nil, 0)
+	for succ := range g.outgoing[n] {
+		delete(g.incoming[succ], n)
+	}
+	for pred := range g.incoming[n] {
+		delete(g.outgoing[pred], n)
+		delete(g.weights[pred], n)
 	}
-	g.incoming[to][from] = struct{}{}
+	delete(g.outgoing, n)
+	delete(g.incoming, n)
+	delete(g.weights, n)
+	delete(g.nodes, n.ID())
+	if g.key != nil && n.Kind == DefaultNode {
+		delete(g.keyed, g.key(n.Value))
+	}
+	if g.root == n {
+		g.root = nil
+	}
+}
+
+// Merge merges the node gone into the node keep: the outgoing edges of gone,
+// along with their weights, are redirected to originate from keep, and gone
+// is removed along with its incoming edges. An edge from gone to keep would
+// become a self-edge of keep, which is dropped unless keep already had one.
+// Edges which keep already had retain their weights. Merging a node into
+// itself, or nodes not in the graph, has no effect.
+func (g *Graph[N]) Merge(keep, gone *Node[N]) {
+	if keep == gone {
+		return
+	}
+	for _, n := range []*Node[N]{keep, gone} {
+		if node, ok := g.nodes[n.ID()]; !ok || node != n {
+			return
+		}
+	}
+	for _, succ := range g.Successors(gone) {
+		if succ == gone || succ == keep || g.HasEdge(keep, succ) {
+			continue
+		}
+		g.SetEdge(keep, succ)
+		if w, ok := g.EdgeWeight(gone, succ); ok {
+			g.SetEdgeWeight(keep, succ, w)
+		}
+	}
+	g.RemoveNode(gone)
+}
+
+// SetEdgeWeight sets the weight (e.g. the execution count) of the edge from
+// the "from" node to the "to" node.
+func (g *Graph[N]) SetEdgeWeight(from, to *Node[N], w float64) {
+	g.record(OpSetEdgeWeight, from, to, w)
+	if _, ok := g.weights[from]; !ok {
+		g.weights[from] = make(map[*Node[N]]float64)
+	}
+	g.weights[from][to] = w
+}
+
+// EdgeWeight returns the weight of the edge from the "from" node to the "to"
+// node. The boolean return value indicates whether a weight has been set.
+func (g *Graph[N]) EdgeWeight(from, to *Node[N]) (float64, bool) {
+	w, ok := g.weights[from][to]
+	return w, ok
 }
 
 // Nodes returns a slice of all nodes in the graph.
@@ -109,27 +454,82 @@ func (g *Graph[N]) Nodes() []*Node[N] {
 	return nodes
 }
 
+// AllNodes returns an iterator over all nodes in the graph, in no particular
+// order. Unlike Nodes, it does not allocate a slice of the nodes. The graph
+// must not be mutated during iteration.
+func (g *Graph[N]) AllNodes() iter.Seq[*Node[N]] {
+	return func(yield func(*Node[N]) bool) {
+		for _, node := range g.nodes {
+			if !yield(node) {
+				return
+			}
+		}
+	}
+}
+
 // Len returns the number of nodes in the graph.
 func (g *Graph[N]) Len() int {
 	return len(g.nodes)
 }
 
-// Successors returns a slice of nodes that are directly reachable from the given node.
-func (g *Graph[N]) Successors(n *Node[N]) []*Node[N] {
-	var succ []*Node[N]
-	for neighbor := range g.outgoing[n] {
-		succ = append(succ, neighbor)
+// Edges returns an iterator over every directed edge of the graph, yielding the
+// source and target node of each edge once. Edges are ordered by their source
+// node, in the order in which the nodes were added to the graph, and then in
+// the order of successors.
+func (g *Graph[N]) Edges() iter.Seq2[*Node[N], *Node[N]] {
+	return func(yield func(from, to *Node[N]) bool) {
+		for _, from := range sortBySeq(g.Nodes()) {
+			for _, to := range g.Successors(from) {
+				if !yield(from, to) {
+					return
+				}
+			}
+		}
 	}
-	return succ
 }
 
-// Predecessors returns a slice of nodes that have a direct edge to the given node.
+// HasEdge reports whether there is an edge from the "from" node to the "to"
+// node.
+func (g *Graph[N]) HasEdge(from, to *Node[N]) bool {
+	_, ok := g.outgoing[from][to]
+	return ok
+}
+
+// OutDegree returns the number of outgoing edges of the given node, or 0 if the
+// node is not in the graph.
+func (g *Graph[N]) OutDegree(n *Node[N]) int {
+	return len(g.outgoing[n])
+}
+
+// InDegree returns the number of incoming edges of the given node, or 0 if the
+// node is not in the graph.
+func (g *Graph[N]) InDegree(n *Node[N]) int {
+	return len(g.incoming[n])
+}
+
+// Successors returns a slice of nodes that are directly reachable from the given node,
+// in the order in which the edges were added to the graph.
+func (g *Graph[N]) Successors(n *Node[N]) []*Node[N] {
+	return neighbors(g.outgoing[n])
+}
+
+// Predecessors returns a slice of nodes that have a direct edge to the given node,
+// in the order in which the edges were added to the graph.
 func (g *Graph[N]) Predecessors(n *Node[N]) []*Node[N] {
-	var preds []*Node[N]
-	for neighbor := range g.incoming[n] {
-		preds = append(preds, neighbor)
+	return neighbors(g.incoming[n])
+}
+
+// neighbors returns the nodes of the given edges, ordered by the sequence
+// numbers of the edges.
+func neighbors[N comparable](edges map[*Node[N]]int) []*Node[N] {
+	var nodes []*Node[N]
+	for neighbor := range edges {
+		nodes = append(nodes, neighbor)
 	}
-	return preds
+	slices.SortFunc(nodes, func(a, b *Node[N]) int {
+		return cmp.Compare(edges[a], edges[b])
+	})
+	return nodes
 }
 
 // DFS performs a depth-first search on the graph.
@@ -159,6 +559,222 @@ func (g *Graph[N]) DFS(pre, post func(n *Node[N])) {
 	visit(g.root)
 }
 
+// BFS performs a breadth-first search on the graph, starting at the root. The
+// 'visit' callback is invoked once for each reachable node, in increasing order
+// of depth, i.e. the length of the shortest path from the root to the node (in
+// number of edges). Nodes of equal depth are visited in the order of
+// successors. BFS has no effect if the graph has no root.
+func (g *Graph[N]) BFS(visit func(n *Node[N], depth int)) {
+	if g.root == nil {
+		return
+	}
+	depth := map[ID[N]]int{g.root.ID(): 0}
+	queue := []*Node[N]{g.root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		visit(n, depth[n.ID()])
+		for _, succ := range g.Successors(n) {
+			if _, ok := depth[succ.ID()]; ok {
+				continue
+			}
+			depth[succ.ID()] = depth[n.ID()] + 1
+			queue = append(queue, succ)
+		}
+	}
+}
+
+// BFSDistances performs a breadth-first search on the graph, starting at the
+// given node, and returns the length of the shortest path from the node to
+// each reachable node (in number of edges).
+func (g *Graph[N]) BFSDistances(from *Node[N]) map[ID[N]]int {
+	dist := map[ID[N]]int{from.ID(): 0}
+	queue := []*Node[N]{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, succ := range g.Successors(n) {
+			if _, ok := dist[succ.ID()]; ok {
+				continue
+			}
+			dist[succ.ID()] = dist[n.ID()] + 1
+			queue = append(queue, succ)
+		}
+	}
+	return dist
+}
+
+// ReachableFrom returns the set of nodes reachable from the given node along
+// edges of the graph, including the node itself. Unlike DFS, it uses an explicit
+// worklist and does not depend on the node ordering.
+func (g *Graph[N]) ReachableFrom(n *Node[N]) map[*Node[N]]bool {
+	return g.reach(n, g.outgoing)
+}
+
+// Reachable reports whether the "to" node is reachable from the "from" node
+// along edges of the graph. Every node is reachable from itself.
+func (g *Graph[N]) Reachable(from, to *Node[N]) bool {
+	return g.reachable(from, to, nil)
+}
+
+// ReachableWithin reports whether the "to" node is reachable from the "from"
+// node along a path whose intermediate nodes are all in the allowed set, i.e.
+// without leaving the set. The "from" and "to" nodes themselves need not be in
+// the set. Every node is reachable from itself.
+func (g *Graph[N]) ReachableWithin(from, to *Node[N], allowed map[ID[N]]struct{}) bool {
+	return g.reachable(from, to, func(n *Node[N]) bool {
+		_, ok := allowed[n.ID()]
+		return ok
+	})
+}
+
+// reachable reports whether the "to" node is reachable from the "from" node,
+// traversing only nodes for which allow returns true (or all nodes if allow is
+// nil), stopping as soon as the node is reached.
+func (g *Graph[N]) reachable(from, to *Node[N], allow func(n *Node[N]) bool) bool {
+	if from.ID() == to.ID() {
+		return true
+	}
+	visited := map[ID[N]]bool{from.ID(): true}
+	queue := []*Node[N]{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, succ := range g.Successors(n) {
+			if succ.ID() == to.ID() {
+				return true
+			}
+			if visited[succ.ID()] || (allow != nil && !allow(succ)) {
+				continue
+			}
+			visited[succ.ID()] = true
+			queue = append(queue, succ)
+		}
+	}
+	return false
+}
+
+// Reaching returns the set of nodes from which the given node is reachable,
+// i.e. the nodes reachable backwards along edges of the graph, including the
+// node itself.
+func (g *Graph[N]) Reaching(n *Node[N]) map[*Node[N]]bool {
+	return g.reach(n, g.incoming)
+}
+
+// Unreachable returns the nodes of the graph which are not reachable from its
+// root, in the order in which they were added to the graph. If the root is
+// nil, all nodes are returned.
+func (g *Graph[N]) Unreachable() []*Node[N] {
+	var reached map[*Node[N]]bool
+	if g.root != nil {
+		reached = g.ReachableFrom(g.root)
+	}
+	var nodes []*Node[N]
+	for _, n := range sortBySeq(g.Nodes()) {
+		if !reached[n] {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Sinks returns the nodes of the graph without successors, i.e. its exits, in
+// the order in which they were added to the graph.
+func (g *Graph[N]) Sinks() []*Node[N] {
+	var nodes []*Node[N]
+	for _, n := range sortBySeq(g.Nodes()) {
+		if g.OutDegree(n) == 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Sources returns the nodes of the graph without predecessors, in the order in
+// which they were added to the graph. In a well-formed control flow graph, the
+// root is the only source.
+func (g *Graph[N]) Sources() []*Node[N] {
+	var nodes []*Node[N]
+	for _, n := range sortBySeq(g.Nodes()) {
+		if g.InDegree(n) == 0 {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Prune removes the nodes of the graph which are not reachable from its root,
+// along with their edges, and returns the removed nodes. Pruning a graph again
+// removes nothing.
+func (g *Graph[N]) Prune() []*Node[N] {
+	nodes := g.Unreachable()
+	for _, n := range nodes {
+		g.RemoveNode(n)
+	}
+	return nodes
+}
+
+// WellFormed reports the defects of the graph as a control flow graph, joined
+// into a single error, or nil if there are none. A well-formed graph has a
+// root from which every node is reachable, no sources other than the root
+// (which may have predecessors, e.g. if a loop starts at the entry), and no
+// edges to or from nodes which are not in the graph.
+func (g *Graph[N]) WellFormed() error {
+	var errs []error
+	if g.root == nil {
+		errs = append(errs, errors.New("graph: no root"))
+	} else {
+		if node, ok := g.nodes[g.root.ID()]; !ok || node != g.root {
+			errs = append(errs, fmt.Errorf("graph: root %v is not in the graph", g.root))
+		}
+		if nodes := g.Unreachable(); len(nodes) > 0 {
+			errs = append(errs, fmt.Errorf("graph: nodes %v are unreachable from root", nodes))
+		}
+	}
+	var sources []*Node[N]
+	for _, n := range g.Sources() {
+		if n != g.root {
+			sources = append(sources, n)
+		}
+	}
+	if len(sources) > 0 {
+		errs = append(errs, fmt.Errorf("graph: sources %v other than root", sources))
+	}
+	foreign := make(map[*Node[N]]bool)
+	for from, succs := range g.outgoing {
+		for to := range succs {
+			for _, n := range []*Node[N]{from, to} {
+				if node, ok := g.nodes[n.ID()]; !ok || node != n {
+					foreign[n] = true
+				}
+			}
+		}
+	}
+	if len(foreign) > 0 {
+		nodes := sortBySeq(slices.Collect(maps.Keys(foreign)))
+		errs = append(errs, fmt.Errorf("graph: edges to or from nodes %v not in the graph", nodes))
+	}
+	return errors.Join(errs...)
+}
+
+// reach returns the set of nodes reachable from the given node along the given
+// adjacency relation.
+func (g *Graph[N]) reach(n *Node[N], adj map[*Node[N]]map[*Node[N]]int) map[*Node[N]]bool {
+	reached := map[*Node[N]]bool{n: true}
+	work := []*Node[N]{n}
+	for len(work) > 0 {
+		v := work[len(work)-1]
+		work = work[:len(work)-1]
+		for w := range adj[v] {
+			if !reached[w] {
+				reached[w] = true
+				work = append(work, w)
+			}
+		}
+	}
+	return reached
+}
+
 // InitOrder initializes the reverse postorder numbering of the graph nodes.
 func (g *Graph[N]) InitOrder() {
 	num := g.Len()
@@ -167,3 +783,86 @@ func (g *Graph[N]) InitOrder() {
 		num--
 	})
 }
+
+// HasCycle reports whether the graph contains a cycle reachable from its root,
+// using a three-color depth-first search. It returns false if the graph has no
+// root.
+func (g *Graph[N]) HasCycle() bool {
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := make(map[ID[N]]int)
+	var visit func(n *Node[N]) bool
+	visit = func(n *Node[N]) bool {
+		color[n.ID()] = grey
+		for _, succ := range g.Successors(n) {
+			switch color[succ.ID()] {
+			case grey:
+				// Edge to a node on the current path, i.e. a back edge.
+				return true
+			case white:
+				if visit(succ) {
+					return true
+				}
+			}
+		}
+		color[n.ID()] = black
+		return false
+	}
+	return g.root != nil && visit(g.root)
+}
+
+// TopologicalOrder returns the nodes of the graph in topological order, i.e.
+// every node precedes its successors, using Kahn's algorithm. Nodes without
+// ordering constraints keep the order in which they were added to the graph.
+// An error is returned if the graph contains a cycle; since control flow
+// graphs usually contain loops, callers should operate on an acyclic graph
+// (e.g. with back edges removed).
+func (g *Graph[N]) TopologicalOrder() ([]*Node[N], error) {
+	indegree := make(map[*Node[N]]int, len(g.nodes))
+	var queue []*Node[N]
+	for _, n := range sortBySeq(g.Nodes()) {
+		indegree[n] = len(g.incoming[n])
+		if indegree[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	order := make([]*Node[N], 0, len(g.nodes))
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		order = append(order, n)
+		for _, succ := range sortBySeq(g.Successors(n)) {
+			indegree[succ]--
+			if indegree[succ] == 0 {
+				queue = append(queue, succ)
+			}
+		}
+	}
+	if len(order) != len(g.nodes) {
+		return nil, errors.New("graph: graph contains a cycle")
+	}
+	return order, nil
+}
+
+// IsBackEdgeCandidate reports whether an edge from the "from" node to the "to"
+// node would be a back edge, based on the reverse postorder numbering of the
+// last call to InitOrder. This is cheap and does not require the edge to
+// exist, which allows querying loop hints while the graph is built
+// incrementally.
+//
+// The answer may be stale: nodes added after the last InitOrder have no order.
+// An edge from such a node to a numbered node is conservatively considered a
+// back edge candidate, while an edge to an unnumbered node is not. Edges added
+// after the last InitOrder may also change the ordering of existing nodes.
+func (g *Graph[N]) IsBackEdgeCandidate(from, to *Node[N]) bool {
+	if to.Order == 0 {
+		return false
+	}
+	if from.Order == 0 {
+		return true
+	}
+	return to.Order <= from.Order
+}
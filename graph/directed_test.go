@@ -0,0 +1,792 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestNewKeyed(t *testing.T) {
+	type block struct {
+		Label string
+		Addr  uint64
+	}
+	g := NewKeyed(func(b block) uint64 { return b.Addr })
+	a := g.Node(block{Label: "loop", Addr: 0x10})
+	b := g.Node(block{Label: "loop", Addr: 0x20})
+	if a == b {
+		t.Fatal("expected blocks with repeated labels to be distinct nodes")
+	}
+	if c := g.Node(block{Label: "renamed", Addr: 0x10}); c != a {
+		t.Fatal("expected blocks with equal keys to be the same node")
+	}
+	if n, ok := g.GetNode(block{Addr: 0x20}); !ok || n != b {
+		t.Fatal("expected lookup by key")
+	}
+	if g.Len() != 2 {
+		t.Fatalf("expected 2 nodes, got %d", g.Len())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 3 -> 4, 4 -> 2, 2 -> 5, 5 -> 6, 6 -> 1.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}, {2, 5}, {5, 6}, {6, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	m := g.Metrics()
+	if m.Nodes != 6 || m.Edges != 7 {
+		t.Fatalf("expected 6 nodes and 7 edges, got %d and %d", m.Nodes, m.Edges)
+	}
+	if m.CyclomaticComplexity != 3 {
+		t.Fatalf("expected cyclomatic complexity 3, got %d", m.CyclomaticComplexity)
+	}
+	if m.MaxInDegree != 2 || m.MaxOutDegree != 2 {
+		t.Fatalf("expected max in/out degree 2, got %d and %d", m.MaxInDegree, m.MaxOutDegree)
+	}
+	if m.BackEdges != 2 {
+		t.Fatalf("expected 2 back edges, got %d", m.BackEdges)
+	}
+	if m.SCCs != 1 {
+		t.Fatalf("expected 1 strongly connected component, got %d", m.SCCs)
+	}
+
+	// The back edges of an irreducible graph depend on the traversal order:
+	// visiting 2 before 3 would find 3 -> 2 and 4 -> 2, while visiting the
+	// successors of 1 in the order of their edges finds only 2 -> 3.
+	g = New[int]()
+	for _, value := range []int{1, 2, 3, 4} {
+		g.Node(value)
+	}
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 3}, {1, 2}, {2, 3}, {3, 2}, {3, 4}, {4, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if m := g.Metrics(); m.BackEdges != 1 {
+		t.Fatalf("expected 1 back edge, got %d", m.BackEdges)
+	}
+}
+
+func TestBFSDistances(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 1 -> 3, 3 -> 1 and an unreachable node 4.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {1, 3}, {3, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.Node(4)
+
+	dist := g.BFSDistances(g.Node(1))
+	for value, want := range map[int]int{1: 0, 2: 1, 3: 1} {
+		if got, ok := dist[g.Node(value).ID()]; !ok || got != want {
+			t.Fatalf("expected distance %d to %d, got %d", want, value, got)
+		}
+	}
+	if _, ok := dist[g.Node(4).ID()]; ok {
+		t.Fatal("expected no distance to unreachable node")
+	}
+}
+
+func TestBFS(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 3 -> 4, 4 -> 2, 2 -> 5, 5 -> 6, 6 -> 1.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}, {2, 5}, {5, 6}, {6, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	var order, depths []int
+	g.BFS(func(n *Node[int], depth int) {
+		order = append(order, n.Value)
+		depths = append(depths, depth)
+	})
+	if !slices.Equal(order, []int{1, 2, 3, 5, 4, 6}) {
+		t.Fatalf("expected order [1 2 3 5 4 6], got %v", order)
+	}
+	if !slices.Equal(depths, []int{0, 1, 2, 2, 3, 3}) {
+		t.Fatalf("expected depths [0 1 2 2 3 3], got %v", depths)
+	}
+
+	// A graph without root is not traversed.
+	New[int]().BFS(func(n *Node[int], depth int) {
+		t.Fatalf("unexpected visit of %v", n)
+	})
+}
+
+func TestCompactIntervalIndices(t *testing.T) {
+	// Create the interval nodes I(7) -> I(3) -> I(42).
+	g := New[int]()
+	g.SetRoot(g.Interval(7))
+	g.SetEdge(g.Interval(7), g.Interval(3))
+	g.SetEdge(g.Interval(3), g.Interval(42))
+
+	mapping := g.CompactIntervalIndicesInPlace()
+	for old, want := range map[int]int{3: 0, 7: 1, 42: 2} {
+		if got, ok := mapping[old]; !ok || got != want {
+			t.Fatalf("expected index %d to map to %d, got %d", old, want, got)
+		}
+	}
+	if g.Root().Idx != 1 {
+		t.Fatalf("expected root index 1, got %d", g.Root().Idx)
+	}
+	if g.Len() != 3 {
+		t.Fatalf("expected 3 nodes, got %d", g.Len())
+	}
+	succs := g.Successors(g.Interval(1))
+	if len(succs) != 1 || succs[0].Idx != 0 {
+		t.Fatalf("expected successor I(0), got %v", succs)
+	}
+}
+
+func TestSetFormatter(t *testing.T) {
+	g := New[int]()
+	n := g.Node(255)
+	if s := n.String(); s != "255" {
+		t.Fatalf("expected default format 255, got %s", s)
+	}
+	g.SetFormatter(func(v int) string { return fmt.Sprintf("%#x", v) })
+	if s := n.String(); s != "0xff" {
+		t.Fatalf("expected formatted 0xff, got %s", s)
+	}
+	if s := g.String(); s != "0xff -> \n" {
+		t.Fatalf("expected formatted graph, got %q", s)
+	}
+}
+
+func TestEdges(t *testing.T) {
+	// Create the graph 3 -> 2, 3 -> 1, 1 -> 2, with nodes added in order 3, 2, 1.
+	g := New[int]()
+	g.SetRoot(g.Node(3))
+	g.SetEdge(g.Node(3), g.Node(2))
+	g.SetEdge(g.Node(3), g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+
+	var edges [][2]int
+	for from, to := range g.Edges() {
+		edges = append(edges, [2]int{from.Value, to.Value})
+	}
+	want := [][2]int{{3, 2}, {3, 1}, {1, 2}}
+	if !slices.Equal(edges, want) {
+		t.Fatalf("expected edges %v, got %v", want, edges)
+	}
+}
+
+func TestReachability(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 3 -> 2, 4 -> 3.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {4, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	values := func(nodes map[*Node[int]]bool) []int {
+		var vs []int
+		for n := range nodes {
+			vs = append(vs, n.Value)
+		}
+		slices.Sort(vs)
+		return vs
+	}
+	if got := values(g.ReachableFrom(g.Node(2))); !slices.Equal(got, []int{2, 3}) {
+		t.Fatalf("expected nodes [2 3] reachable from 2, got %v", got)
+	}
+	if got := values(g.Reaching(g.Node(3))); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Fatalf("expected nodes [1 2 3 4] reaching 3, got %v", got)
+	}
+}
+
+func TestRecordingGraph(t *testing.T) {
+	g, log := NewRecordingGraph[int]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+	g.SetEdgeWeight(g.Node(1), g.Node(2), 3)
+	g.SetEdge(g.Node(2), g.Node(1))
+	g.RemoveEdge(g.Node(2), g.Node(1))
+
+	want := "g.Node(1)\ng.SetRoot(g.Node(1))\ng.Node(2)\ng.SetEdge(g.Node(1), g.Node(2))\n" +
+		"g.SetEdgeWeight(g.Node(1), g.Node(2), 3)\ng.SetEdge(g.Node(2), g.Node(1))\ng.RemoveEdge(g.Node(2), g.Node(1))\n"
+	if s := log.String(); s != want {
+		t.Fatalf("expected log\n%s\ngot\n%s", want, s)
+	}
+
+	h := New[int]()
+	log.Replay(h)
+	if h.String() != g.String() || h.Root().Value != 1 {
+		t.Fatalf("expected replayed graph\n%v\ngot\n%v", g, h)
+	}
+	if w, ok := h.EdgeWeight(h.Node(1), h.Node(2)); !ok || w != 3 {
+		t.Fatalf("expected replayed weight 3, got %v", w)
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 1 -> 3, 3 -> 1.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {1, 3}, {3, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	n2 := g.Node(2)
+	g.RemoveNode(n2)
+	if _, ok := g.GetNode(2); ok || g.Len() != 2 {
+		t.Fatal("expected node 2 to be removed")
+	}
+	for _, n := range g.Nodes() {
+		if slices.Contains(g.Successors(n), n2) || slices.Contains(g.Predecessors(n), n2) {
+			t.Fatalf("expected no edges to removed node from %v", n)
+		}
+	}
+
+	g.RemoveNode(g.Root())
+	if g.Root() != nil {
+		t.Fatal("expected root to be unset after removing it")
+	}
+	if preds := g.Predecessors(g.Node(3)); len(preds) != 0 {
+		t.Fatalf("expected no predecessors of 3, got %v", preds)
+	}
+}
+
+func TestRemoveEdge(t *testing.T) {
+	g := New[int]()
+	n1, n2 := g.Node(1), g.Node(2)
+	g.SetRoot(n1)
+	g.SetEdge(n1, n2)
+	g.SetEdge(n2, n1)
+
+	g.RemoveEdge(n1, n2)
+	if slices.Contains(g.Successors(n1), n2) || slices.Contains(g.Predecessors(n2), n1) {
+		t.Fatal("expected edge 1 -> 2 to be removed")
+	}
+	if !slices.Contains(g.Successors(n2), n1) || g.Len() != 2 {
+		t.Fatal("expected edge 2 -> 1 and both nodes to remain")
+	}
+
+	// Removing missing edges or edges of unknown nodes has no effect.
+	g.RemoveEdge(n1, n2)
+	g.RemoveEdge(&Node[int]{Value: 3}, n1)
+	if !slices.Contains(g.Predecessors(n1), n2) {
+		t.Fatal("expected edge 2 -> 1 to remain")
+	}
+}
+
+func TestHasEdge(t *testing.T) {
+	g := New[int]()
+	n1, n2 := g.Node(1), g.Node(2)
+	g.SetEdge(n1, n2)
+	if !g.HasEdge(n1, n2) {
+		t.Fatal("expected edge 1 -> 2")
+	}
+	if g.HasEdge(n2, n1) || g.HasEdge(&Node[int]{Value: 3}, n1) {
+		t.Fatal("expected no edge 2 -> 1 nor from unknown node")
+	}
+}
+
+func TestDegree(t *testing.T) {
+	// Create the graph 1 -> 2, 1 -> 3, 2 -> 3.
+	g := New[int]()
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if g.OutDegree(g.Node(1)) != 2 || g.InDegree(g.Node(1)) != 0 {
+		t.Fatal("expected node 1 to have out-degree 2 and in-degree 0")
+	}
+	if g.OutDegree(g.Node(3)) != 0 || g.InDegree(g.Node(3)) != 2 {
+		t.Fatal("expected node 3 to have out-degree 0 and in-degree 2")
+	}
+	if unknown := (&Node[int]{Value: 4}); g.OutDegree(unknown) != 0 || g.InDegree(unknown) != 0 {
+		t.Fatal("expected unknown node to have degree 0")
+	}
+}
+
+func TestClone(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 1 with a weighted edge.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+	g.SetEdge(g.Node(2), g.Node(1))
+	g.SetEdgeWeight(g.Node(1), g.Node(2), 5)
+	g.InitOrder()
+
+	c := g.Clone()
+	if c.String() != g.String() || c.Root().Value != 1 || c.Root() == g.Root() {
+		t.Fatalf("expected cloned graph\n%v\ngot\n%v", g, c)
+	}
+	if c.Root().Order != 0 {
+		t.Fatal("expected order of cloned nodes to be reset")
+	}
+	if w, ok := c.EdgeWeight(c.Node(1), c.Node(2)); !ok || w != 5 {
+		t.Fatalf("expected cloned weight 5, got %v", w)
+	}
+
+	// Mutating the clone leaves the source graph untouched.
+	c.Node(2).IsLoopHead = true
+	c.SetEdge(c.Node(2), c.Node(3))
+	if g.Node(2).IsLoopHead || g.Len() != 2 || len(g.Successors(g.Node(2))) != 1 {
+		t.Fatal("expected source graph to be untouched")
+	}
+}
+
+func TestReverse(t *testing.T) {
+	// Create the graph 1 -> 2, 2 -> 3, 3 -> 4, 4 -> 2, 2 -> 5, 5 -> 6, 6 -> 1.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 4}, {4, 2}, {2, 5}, {5, 6}, {6, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+
+	r := g.Reverse()
+	if r.Root() != nil {
+		t.Fatal("expected reversed graph without root")
+	}
+	values := func(nodes []*Node[int]) []int {
+		var vs []int
+		for _, n := range nodes {
+			vs = append(vs, n.Value)
+		}
+		slices.Sort(vs)
+		return vs
+	}
+	for _, n := range g.Nodes() {
+		rn, ok := r.GetNode(n.Value)
+		if !ok {
+			t.Fatalf("expected node %v in reversed graph", n)
+		}
+		if !slices.Equal(values(r.Successors(rn)), values(g.Predecessors(n))) {
+			t.Fatalf("expected successors of %v to be its original predecessors", n)
+		}
+		if !slices.Equal(values(r.Predecessors(rn)), values(g.Successors(n))) {
+			t.Fatalf("expected predecessors of %v to be its original successors", n)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	// Create the graph 1 -> 2 with the dead blocks 3 -> 4.
+	g := New[int]()
+	for _, edge := range [][2]int{{1, 2}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if nodes := g.Unreachable(); len(nodes) != 4 {
+		t.Fatalf("expected all 4 nodes unreachable without root, got %v", nodes)
+	}
+	g.SetRoot(g.Node(1))
+	nodes := g.Unreachable()
+	if len(nodes) != 2 || nodes[0].Value != 3 || nodes[1].Value != 4 {
+		t.Fatalf("expected unreachable nodes [3 4], got %v", nodes)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	// Create the graph 1 -> 2 with the dead blocks 3 -> 4, 4 -> 2.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {3, 4}, {4, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if removed := g.Prune(); len(removed) != 2 {
+		t.Fatalf("expected 2 removed nodes, got %v", removed)
+	}
+	if g.Len() != 2 || len(g.Predecessors(g.Node(2))) != 1 {
+		t.Fatalf("expected pruned graph 1 -> 2, got\n%v", g)
+	}
+	if removed := g.Prune(); len(removed) != 0 {
+		t.Fatalf("expected no removed nodes, got %v", removed)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(2))
+	g.SetEdge(g.Node(2), g.Node(2))
+	g.Node(2).IsLoopHead = true
+
+	want := "digraph {\n" +
+		"\t\"0:0:1\" [label=\"1\" peripheries=2]\n" +
+		"\t\"0:0:2\" [label=\"2\" style=filled fillcolor=salmon]\n" +
+		"\t\"0:0:1\" -> \"0:0:2\"\n" +
+		"\t\"0:0:2\" -> \"0:0:2\"\n" +
+		"}\n"
+	if dot := g.DOT(); dot != want {
+		t.Fatalf("expected DOT\n%s\ngot\n%s", want, dot)
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	// Create the acyclic graph 1 -> 3, 1 -> 2, 2 -> 3, 3 -> 4.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 3}, {1, 2}, {2, 3}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []int
+	for _, n := range order {
+		values = append(values, n.Value)
+	}
+	if !slices.Equal(values, []int{1, 2, 3, 4}) {
+		t.Fatalf("expected order [1 2 3 4], got %v", values)
+	}
+
+	// Add the back edge 4 -> 2.
+	g.SetEdge(g.Node(4), g.Node(2))
+	if _, err := g.TopologicalOrder(); err == nil {
+		t.Fatal("expected error for cyclic graph")
+	}
+}
+
+func TestAttr(t *testing.T) {
+	g := New[int]()
+	n1, n2 := g.Node(1), g.Node(2)
+	g.SetEdge(n1, n2)
+
+	var insts Attr[int, []string]
+	if _, ok := insts.Get(n1); ok {
+		t.Fatal("expected no value in empty side-table")
+	}
+	insts.Set(n1, []string{"cmp", "jne"})
+	if v, ok := insts.Get(n1); !ok || !slices.Equal(v, []string{"cmp", "jne"}) {
+		t.Fatalf("expected [cmp jne], got %v", v)
+	}
+	if _, ok := insts.Get(n2); ok {
+		t.Fatal("expected no value for node 2")
+	}
+
+	// Values are keyed by ID and thus shared with the nodes of a clone.
+	c := g.Clone()
+	if _, ok := insts.Get(c.Node(1)); !ok {
+		t.Fatal("expected value for cloned node 1")
+	}
+	insts.Delete(n1)
+	if _, ok := insts.Get(n1); ok {
+		t.Fatal("expected no value after delete")
+	}
+}
+
+func TestNeighborOrder(t *testing.T) {
+	// Create a node 1 with successors added in the order 4, 2, 3, and a node
+	// 5 with predecessors added in the order 3, 1.
+	g := New[int]()
+	for _, edge := range [][2]int{{1, 4}, {1, 2}, {1, 3}, {3, 5}, {1, 5}, {1, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	values := func(nodes []*Node[int]) []int {
+		var values []int
+		for _, n := range nodes {
+			values = append(values, n.Value)
+		}
+		return values
+	}
+	for range 10 {
+		if succs := values(g.Successors(g.Node(1))); !slices.Equal(succs, []int{4, 2, 3, 5}) {
+			t.Fatalf("expected successors [4 2 3 5], got %v", succs)
+		}
+		if preds := values(g.Predecessors(g.Node(5))); !slices.Equal(preds, []int{3, 1}) {
+			t.Fatalf("expected predecessors [3 1], got %v", preds)
+		}
+	}
+	c := g.Clone()
+	if succs := values(c.Successors(c.Node(1))); !slices.Equal(succs, []int{4, 2, 3, 5}) {
+		t.Fatalf("expected cloned successors [4 2 3 5], got %v", succs)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	g, log := NewRecordingGraph[int]()
+	n1 := g.Node(1)
+	s1 := g.Split(1, 1)
+	g.SetEdge(s1, n1)
+	if s1 == n1 || g.Split(1, 1) != s1 {
+		t.Fatal("expected a distinct split node, returned again on repeated calls")
+	}
+	if s := s1.String(); s != "1'1" {
+		t.Fatalf("expected split node 1'1, got %s", s)
+	}
+	if c := g.Clone(); !c.HasEdge(c.Split(1, 1), c.Node(1)) {
+		t.Fatal("expected split node in clone")
+	}
+	want := "g.Node(1)\ng.Split(1, 1)\ng.SetEdge(g.Split(1, 1), g.Node(1))\n"
+	if got := log.String(); got != want {
+		t.Fatalf("expected log\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestAllNodes(t *testing.T) {
+	g := New[int]()
+	for _, edge := range [][2]int{{1, 2}, {2, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	var values []int
+	for n := range g.AllNodes() {
+		values = append(values, n.Value)
+	}
+	if slices.Sort(values); !slices.Equal(values, []int{1, 2, 3}) {
+		t.Fatalf("expected nodes [1 2 3], got %v", values)
+	}
+	// Stop iterating early.
+	for range g.AllNodes() {
+		break
+	}
+}
+
+func TestHasCycle(t *testing.T) {
+	// Create the DAG 1 -> 2, 1 -> 3, 2 -> 3.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if g.HasCycle() {
+		t.Fatal("expected no cycle in DAG")
+	}
+
+	// Add the back edge 3 -> 2.
+	g.SetEdge(g.Node(3), g.Node(2))
+	if !g.HasCycle() {
+		t.Fatal("expected cycle")
+	}
+
+	if New[int]().HasCycle() {
+		t.Fatal("expected no cycle in graph without root")
+	}
+}
+
+func TestReachableWithin(t *testing.T) {
+	// Create the conditional 1 -> {2, 3} merging at 4, and 4 -> 5.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}, {4, 5}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if !g.Reachable(g.Node(1), g.Node(5)) || g.Reachable(g.Node(5), g.Node(1)) {
+		t.Fatal("expected 5 to be reachable from 1 only")
+	}
+	if !g.Reachable(g.Node(3), g.Node(3)) {
+		t.Fatal("expected node to be reachable from itself")
+	}
+
+	// Both arms reach the follow 4 within the region {2, 3}, but not 5.
+	region := map[ID[int]]struct{}{g.Node(2).ID(): {}, g.Node(3).ID(): {}}
+	for _, arm := range []int{2, 3} {
+		if !g.ReachableWithin(g.Node(arm), g.Node(4), region) {
+			t.Fatalf("expected 4 to be reachable from %d within region", arm)
+		}
+	}
+	if g.ReachableWithin(g.Node(1), g.Node(5), region) {
+		t.Fatal("expected 5 not to be reachable within region")
+	}
+}
+
+func TestSinksSources(t *testing.T) {
+	// Create the graph 1 -> 2 -> {3, 4} with the dead block 5 -> 4.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 4}, {5, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	values := func(nodes []*Node[int]) []int {
+		var vs []int
+		for _, n := range nodes {
+			vs = append(vs, n.Value)
+		}
+		return vs
+	}
+	if sinks := values(g.Sinks()); !slices.Equal(sinks, []int{3, 4}) {
+		t.Fatalf("expected sinks [3 4], got %v", sinks)
+	}
+	if sources := values(g.Sources()); !slices.Equal(sources, []int{1, 5}) {
+		t.Fatalf("expected sources [1 5], got %v", sources)
+	}
+}
+
+func TestWellFormed(t *testing.T) {
+	// Create the loop 1 -> 2 -> 1 starting at the entry, left from 2 to 3.
+	g := New[int]()
+	if err := g.WellFormed(); err == nil {
+		t.Fatal("expected error for graph without root")
+	}
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 1}, {2, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	if err := g.WellFormed(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Add the dead block 4 -> 3 and an edge to a node of another graph.
+	g.SetEdge(g.Node(4), g.Node(3))
+	g.SetEdge(g.Node(3), New[int]().Node(5))
+	err := g.WellFormed()
+	if err == nil {
+		t.Fatal("expected errors for dead block and foreign node")
+	}
+	want := "graph: nodes [4] are unreachable from root\ngraph: sources [4] other than root\ngraph: edges to or from nodes [5] not in the graph"
+	if err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err)
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	// Create the loop 2 -> 3 -> 2 after the entry 1, left from 3 to 4, with a
+	// weighted back edge.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}, {3, 4}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.SetEdgeWeight(g.Node(3), g.Node(2), 7)
+
+	// Extract the loop body, dropping the edges from 1 and to 4.
+	s := g.Subgraph([]*Node[int]{g.Node(3), g.Node(2)}, g.Node(2))
+	if s.Len() != 2 || s.Root() == nil || s.Root().Value != 2 || s.Root() == g.Node(2) {
+		t.Fatalf("expected subgraph of 2 copied nodes rooted at 2, got\n%v", s)
+	}
+	var edges [][2]int
+	for from, to := range s.Edges() {
+		edges = append(edges, [2]int{from.Value, to.Value})
+	}
+	if !slices.Equal(edges, [][2]int{{2, 3}, {3, 2}}) {
+		t.Fatalf("expected edges [[2 3] [3 2]], got %v", edges)
+	}
+	if w, ok := s.EdgeWeight(s.Node(3), s.Node(2)); !ok || w != 7 {
+		t.Fatalf("expected weight 7, got %v", w)
+	}
+
+	// A root outside of the nodes leaves the subgraph without root.
+	if s := g.Subgraph([]*Node[int]{g.Node(4)}, g.Node(1)); s.Root() != nil {
+		t.Fatalf("expected no root, got %v", s.Root())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// Create the chain 1 -> 2 -> {3, 1} with a weighted edge from 2 to 3.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {2, 1}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.SetEdgeWeight(g.Node(2), g.Node(3), 4)
+
+	// Merging 2 into 1 redirects 2 -> 3 and drops the self-edge of 1.
+	g.Merge(g.Node(1), g.Node(2))
+	if _, ok := g.GetNode(2); ok || g.Len() != 2 {
+		t.Fatalf("expected 2 to be removed, got\n%v", g)
+	}
+	if !g.HasEdge(g.Node(1), g.Node(3)) || g.HasEdge(g.Node(1), g.Node(1)) {
+		t.Fatalf("expected only edge 1 -> 3, got\n%v", g)
+	}
+	if w, ok := g.EdgeWeight(g.Node(1), g.Node(3)); !ok || w != 4 {
+		t.Fatalf("expected weight 4, got %v", w)
+	}
+
+	// A self-edge which existed before is kept.
+	g.SetEdge(g.Node(1), g.Node(1))
+	g.SetEdge(g.Node(1), g.Node(4))
+	g.SetEdge(g.Node(4), g.Node(1))
+	g.Merge(g.Node(1), g.Node(4))
+	if !g.HasEdge(g.Node(1), g.Node(1)) {
+		t.Fatalf("expected self-edge of 1 to be kept, got\n%v", g)
+	}
+}
+
+func TestReRoot(t *testing.T) {
+	// Create the entry 1 of the loop 2 <-> 3, as marked by structuring.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {2, 3}, {3, 2}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+	for _, n := range []*Node[int]{g.Node(2), g.Node(3)} {
+		n.IsLoopNode = true
+	}
+	g.Node(2).IsLoopHead = true
+	g.Node(3).IsLoopLatch = true
+
+	// Re-rooting at 2 clears the derived state of every node.
+	g.ReRoot(g.Node(2))
+	for n := range g.AllNodes() {
+		if n.Order != 0 || n.IsLoopNode || n.IsLoopHead || n.IsLoopLatch {
+			t.Fatalf("expected cleared state of %v, got %+v", n, *n)
+		}
+	}
+
+	// The order is recomputed from the new root, leaving 1 unreachable.
+	g.InitOrder()
+	orders := []int{g.Node(1).Order, g.Node(2).Order, g.Node(3).Order}
+	if !slices.Equal(orders, []int{0, 2, 3}) {
+		t.Fatalf("expected orders [0 2 3], got %v", orders)
+	}
+}
+
+func TestIsBackEdgeCandidate(t *testing.T) {
+	// Create the branches 1 -> {2, 3}, such that the reverse postorder is
+	// 1, 3, 2.
+	g := New[int]()
+	g.SetRoot(g.Node(1))
+	for _, edge := range [][2]int{{1, 2}, {1, 3}} {
+		g.SetEdge(g.Node(edge[0]), g.Node(edge[1]))
+	}
+	g.InitOrder()
+	n1, n2, n3 := g.Node(1), g.Node(2), g.Node(3)
+	if !g.IsBackEdgeCandidate(n2, n1) || g.IsBackEdgeCandidate(n1, n2) {
+		t.Fatal("expected only 2 -> 1 to be a back edge candidate")
+	}
+	if !g.IsBackEdgeCandidate(n2, n3) {
+		t.Fatal("expected 2 -> 3 to be a back edge candidate")
+	}
+
+	// Adding the edge 2 -> 3 makes 3 a successor of 2, but the answer is
+	// stale until the order is recomputed.
+	g.SetEdge(n2, n3)
+	if !g.IsBackEdgeCandidate(n2, n3) {
+		t.Fatal("expected stale answer for 2 -> 3 before InitOrder")
+	}
+	// A node added after InitOrder has no order: an edge from it to a
+	// numbered node is a candidate, while an edge to it is not.
+	n4 := g.Node(4)
+	g.SetEdge(n3, n4)
+	if !g.IsBackEdgeCandidate(n4, n1) || g.IsBackEdgeCandidate(n3, n4) {
+		t.Fatal("expected 4 -> 1 to be a back edge candidate, but not 3 -> 4")
+	}
+
+	g.InitOrder()
+	if g.IsBackEdgeCandidate(n2, n3) || g.IsBackEdgeCandidate(n3, n4) {
+		t.Fatal("expected 2 -> 3 and 3 -> 4 not to be back edge candidates after InitOrder")
+	}
+	if !g.IsBackEdgeCandidate(n4, n1) {
+		t.Fatal("expected 4 -> 1 to be a back edge candidate after InitOrder")
+	}
+}
+
+func TestNodeKey(t *testing.T) {
+	// Create nodes of every kind whose values or indices coincide.
+	g := New[any]()
+	g.SetRoot(g.Node(1))
+	g.SetEdge(g.Node(1), g.Node("1"))
+	g.SetEdge(g.Node("1"), g.Interval(1))
+	g.SetEdge(g.Interval(1), g.Split(1, 1))
+
+	keys := make([]string, 0)
+	for _, n := range sortBySeq(g.Nodes()) {
+		keys = append(keys, n.Key())
+	}
+	if unique := slices.Compact(slices.Sorted(slices.Values(keys))); len(unique) != 4 {
+		t.Fatalf("expected 4 unique keys, got %v", keys)
+	}
+
+	// The keys of cloned nodes match those of the source nodes.
+	clones := make([]string, 0)
+	for _, n := range sortBySeq(g.Clone().Nodes()) {
+		clones = append(clones, n.Key())
+	}
+	if !slices.Equal(clones, keys) {
+		t.Fatalf("expected keys %v after Clone, got %v", keys, clones)
+	}
+}
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DOT returns a Graphviz DOT representation of the graph, with one line per
+// node, labeled by its string representation, and one line per edge. Loop
+// headers, latches and other loop nodes are filled with distinct colors, based
+// on the loop flags of the nodes.
+func (g *Graph[N]) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph {\n")
+	for _, n := range sortBySeq(g.Nodes()) {
+		fmt.Fprintf(&sb, "\t%s [label=%s", strconv.Quote(n.Key()), strconv.Quote(n.String()))
+		switch {
+		case n.IsLoopHead:
+			sb.WriteString(" style=filled fillcolor=salmon")
+		case n.IsLoopLatch:
+			sb.WriteString(" style=filled fillcolor=lightblue")
+		case n.IsLoopNode:
+			sb.WriteString(" style=filled fillcolor=lightyellow")
+		}
+		if n == g.root {
+			sb.WriteString(" peripheries=2")
+		}
+		sb.WriteString("]\n")
+	}
+	for from, to := range g.Edges() {
+		fmt.Fprintf(&sb, "\t%s -> %s\n", strconv.Quote(from.Key()), strconv.Quote(to.Key()))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,111 @@
+package graph
+
+// GraphMetrics holds metrics of a directed graph.
+type GraphMetrics struct {
+	// Number of nodes.
+	Nodes int
+	// Number of edges.
+	Edges int
+	// Maximum number of incoming edges of a node.
+	MaxInDegree int
+	// Maximum number of outgoing edges of a node.
+	MaxOutDegree int
+	// Number of back edges found by a depth-first search from the root.
+	BackEdges int
+	// Number of strongly connected components.
+	SCCs int
+	// Cyclomatic complexity, i.e. E - N + 2.
+	CyclomaticComplexity int
+}
+
+// Metrics computes metrics of the graph.
+func (g *Graph[N]) Metrics() GraphMetrics {
+	var m GraphMetrics
+	m.Nodes = g.Len()
+	for _, node := range g.nodes {
+		m.Edges += len(g.outgoing[node])
+		m.MaxInDegree = max(m.MaxInDegree, len(g.incoming[node]))
+		m.MaxOutDegree = max(m.MaxOutDegree, len(g.outgoing[node]))
+	}
+	m.BackEdges = g.countBackEdges()
+	m.SCCs = len(g.Components())
+	m.CyclomaticComplexity = m.Edges - m.Nodes + 2
+	return m
+}
+
+// countBackEdges returns the number of back edges, i.e. edges to a node on the
+// current path of a depth-first search from the root. The search visits the
+// successors of each node in the order of their edges, as DFS does, since the
+// number of back edges of an irreducible graph depends on the traversal order.
+func (g *Graph[N]) countBackEdges() int {
+	if g.root == nil {
+		return 0
+	}
+	onPath := make(map[*Node[N]]bool)
+	visited := make(map[*Node[N]]bool)
+	count := 0
+	var visit func(n *Node[N])
+	visit = func(n *Node[N]) {
+		visited[n] = true
+		onPath[n] = true
+		for _, succ := range g.Successors(n) {
+			if onPath[succ] {
+				count++
+			} else if !visited[succ] {
+				visit(succ)
+			}
+		}
+		onPath[n] = false
+	}
+	visit(g.root)
+	return count
+}
+
+// Components returns the strongly connected components of the graph using
+// Tarjan's algorithm, in the order in which they are completed. The search
+// visits nodes and their successors in the order in which they were added to
+// the graph, such that the result is deterministic.
+func (g *Graph[N]) Components() [][]*Node[N] {
+	index := make(map[*Node[N]]int)
+	lowlink := make(map[*Node[N]]int)
+	onStack := make(map[*Node[N]]bool)
+	var stack []*Node[N]
+	var sccs [][]*Node[N]
+
+	var connect func(v *Node[N])
+	connect = func(v *Node[N]) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range sortBySeq(g.Successors(v)) {
+			if _, ok := index[w]; !ok {
+				connect(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		// v is the root of a strongly connected component.
+		if lowlink[v] == index[v] {
+			var scc []*Node[N]
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, node := range sortBySeq(g.Nodes()) {
+		if _, ok := index[node]; !ok {
+			connect(node)
+		}
+	}
+	return sccs
+}
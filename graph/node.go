@@ -10,15 +10,21 @@ const (
 	DefaultNode Kind = iota
 	// IntervalNode is an interval node.
 	IntervalNode
+	// ExitNode is a synthetic exit node, which is not part of any graph but
+	// succeeds all of its sink nodes (e.g. the root of a post-dominator tree).
+	ExitNode
+	// SplitNode is a copy of a default node created by node splitting, with
+	// the value of the default node and an index distinguishing the copies.
+	SplitNode
 )
 
 // ID is a unique identifier for a node.
 type ID[N comparable] struct {
 	// Kind of the node.
 	Kind Kind
-	// Index of the interval node.
+	// Index of the interval node or split node.
 	Idx int
-	// Value of the default node.
+	// Value of the default node or split node.
 	Value N
 }
 
@@ -27,9 +33,9 @@ type Node[N comparable] struct {
 	// Kind of the node.
 	// Either a default node or an interval node.
 	Kind Kind
-	// Value of the default node.
+	// Value of the default node or split node.
 	Value N
-	// Index of the interval node.
+	// Index of the interval node or split node.
 	Idx int
 
 	// Order of the node in the graph.
@@ -42,6 +48,11 @@ type Node[N comparable] struct {
 	IsLoopHead bool
 	// Node used as latch node in loop.
 	IsLoopLatch bool
+
+	// Graph the node belongs to, used for formatting.
+	graph *Graph[N]
+	// Sequence number of the node in the order of addition to the graph.
+	seq int
 }
 
 // ID returns the unique identifier of the node.
@@ -53,13 +64,28 @@ func (n *Node[N]) ID() ID[N] {
 	}
 }
 
+// Key returns a string key uniquely identifying the node, suitable for use in
+// string-keyed maps (e.g. JSON objects). It combines the kind and index of the
+// node with the Go-syntax representation of its value, which unlike String
+// distinguishes e.g. the string "1" from the integer 1.
+func (n *Node[N]) Key() string {
+	return fmt.Sprintf("%d:%d:%#v", n.Kind, n.Idx, n.Value)
+}
+
 // String returns a string representation of the node.
 func (n *Node[N]) String() string {
 	switch n.Kind {
 	case DefaultNode:
+		if n.graph != nil {
+			return n.graph.Format(n.Value)
+		}
 		return fmt.Sprintf("%v", n.Value)
 	case IntervalNode:
 		return fmt.Sprintf("I(%d)", n.Idx)
+	case ExitNode:
+		return "exit"
+	case SplitNode:
+		return fmt.Sprintf("%s'%d", n.graph.Format(n.Value), n.Idx)
 	}
 	return ""
 }
@@ -0,0 +1,163 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MutationOp is the kind of operation of a graph mutation.
+type MutationOp uint8
+
+// Mutation operations.
+const (
+	// OpNode adds a default node (Node).
+	OpNode MutationOp = iota
+	// OpInterval adds an interval node (Interval).
+	OpInterval
+	// OpSetRoot sets the root node (SetRoot).
+	OpSetRoot
+	// OpReRoot sets the root node and clears derived node state (ReRoot).
+	OpReRoot
+	// OpSetEdge adds an edge (SetEdge).
+	OpSetEdge
+	// OpRemoveEdge removes an edge (RemoveEdge).
+	OpRemoveEdge
+	// OpSetEdgeWeight sets the weight of an edge (SetEdgeWeight).
+	OpSetEdgeWeight
+	// OpCompactIntervalIndices renumbers interval nodes
+	// (CompactIntervalIndicesInPlace).
+	OpCompactIntervalIndices
+	// OpRemoveNode removes a node (RemoveNode).
+	OpRemoveNode
+	// OpSplit adds a split node (Split).
+	OpSplit
+)
+
+// Mutation is a recorded mutation of a graph.
+type Mutation[N comparable] struct {
+	// Operation of the mutation.
+	Op MutationOp
+	// Node affected by node and root operations, and source node of edge
+	// operations.
+	From ID[N]
+	// Target node of edge operations.
+	To ID[N]
+	// Weight of OpSetEdgeWeight.
+	Weight float64
+}
+
+// MutationLog is an ordered log of the mutations of a recording graph.
+type MutationLog[N comparable] struct {
+	mutations []Mutation[N]
+}
+
+// NewRecordingGraph creates a new directed graph which records each of its
+// mutations (i.e. the addition of nodes, changes of the root and the addition,
+// removal and weighting of edges) into the returned log. The log may be
+// replayed to reproduce the graph exactly.
+func NewRecordingGraph[N comparable]() (*Graph[N], *MutationLog[N]) {
+	g := New[N]()
+	g.log = &MutationLog[N]{}
+	return g, g.log
+}
+
+// record notes a mutation with the given operation, nodes and weight: it
+// advances the version of the graph, and appends the mutation to the log of
+// the graph, if recording.
+func (g *Graph[N]) record(op MutationOp, from, to *Node[N], w float64) {
+	g.version++
+	if g.log == nil {
+		return
+	}
+	m := Mutation[N]{Op: op, Weight: w}
+	if from != nil {
+		m.From = from.ID()
+	}
+	if to != nil {
+		m.To = to.ID()
+	}
+	g.log.mutations = append(g.log.mutations, m)
+}
+
+// Version returns the version of the graph, which changes with every mutation
+// of the graph (e.g. adding a node or edge). Analyses derived from the graph
+// may compare versions to determine whether they are stale.
+func (g *Graph[N]) Version() uint64 {
+	return g.version
+}
+
+// Mutations returns the recorded mutations in order.
+func (l *MutationLog[N]) Mutations() []Mutation[N] {
+	return l.mutations
+}
+
+// Replay applies the recorded mutations in order onto the given graph, which is
+// typically freshly created.
+func (l *MutationLog[N]) Replay(g *Graph[N]) {
+	node := func(id ID[N]) *Node[N] {
+		switch id.Kind {
+		case IntervalNode:
+			return g.Interval(id.Idx)
+		case SplitNode:
+			return g.Split(id.Value, id.Idx)
+		}
+		return g.Node(id.Value)
+	}
+	for _, m := range l.mutations {
+		switch m.Op {
+		case OpNode, OpInterval, OpSplit:
+			node(m.From)
+		case OpSetRoot:
+			g.SetRoot(node(m.From))
+		case OpReRoot:
+			g.ReRoot(node(m.From))
+		case OpSetEdge:
+			g.SetEdge(node(m.From), node(m.To))
+		case OpRemoveEdge:
+			g.RemoveEdge(node(m.From), node(m.To))
+		case OpRemoveNode:
+			g.RemoveNode(node(m.From))
+		case OpSetEdgeWeight:
+			g.SetEdgeWeight(node(m.From), node(m.To), m.Weight)
+		case OpCompactIntervalIndices:
+			g.CompactIntervalIndicesInPlace()
+		}
+	}
+}
+
+// String returns the recorded mutations as Go statements operating on a graph
+// g, one per line, such that they may be pasted into a test case.
+func (l *MutationLog[N]) String() string {
+	node := func(id ID[N]) string {
+		switch id.Kind {
+		case IntervalNode:
+			return fmt.Sprintf("g.Interval(%d)", id.Idx)
+		case SplitNode:
+			return fmt.Sprintf("g.Split(%#v, %d)", id.Value, id.Idx)
+		}
+		return fmt.Sprintf("g.Node(%#v)", id.Value)
+	}
+	var sb strings.Builder
+	for _, m := range l.mutations {
+		switch m.Op {
+		case OpNode, OpInterval, OpSplit:
+			sb.WriteString(node(m.From))
+		case OpSetRoot:
+			fmt.Fprintf(&sb, "g.SetRoot(%s)", node(m.From))
+		case OpReRoot:
+			fmt.Fprintf(&sb, "g.ReRoot(%s)", node(m.From))
+		case OpSetEdge:
+			fmt.Fprintf(&sb, "g.SetEdge(%s, %s)", node(m.From), node(m.To))
+		case OpRemoveEdge:
+			fmt.Fprintf(&sb, "g.RemoveEdge(%s, %s)", node(m.From), node(m.To))
+		case OpRemoveNode:
+			fmt.Fprintf(&sb, "g.RemoveNode(%s)", node(m.From))
+		case OpSetEdgeWeight:
+			fmt.Fprintf(&sb, "g.SetEdgeWeight(%s, %s, %v)", node(m.From), node(m.To), m.Weight)
+		case OpCompactIntervalIndices:
+			sb.WriteString("g.CompactIntervalIndicesInPlace()")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
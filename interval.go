@@ -1,6 +1,8 @@
 package decompile
 
 import (
+	"cmp"
+	"fmt"
 	"slices"
 	"strings"
 
@@ -37,12 +39,28 @@ func (i *Interval[N]) Contains(node *graph.Node[N]) bool {
 	return ok
 }
 
-// Nodes returns the nodes in the interval.
+// Nodes returns the nodes in the interval, starting with the header, followed
+// by the other nodes sorted by their order in the graph (as initialized by
+// InitOrder), and then by their ID (kind, index and key).
 func (i *Interval[N]) Nodes() []*graph.Node[N] {
 	nodes := make([]*graph.Node[N], 0, len(i.nodes))
 	for _, node := range i.nodes {
 		nodes = append(nodes, node)
 	}
+	slices.SortFunc(nodes, func(a, b *graph.Node[N]) int {
+		switch {
+		case a.ID() == i.head.ID():
+			return -1
+		case b.ID() == i.head.ID():
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(a.Order, b.Order),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.Idx, b.Idx),
+			cmp.Compare(a.Key(), b.Key()),
+		)
+	})
 	return nodes
 }
 
@@ -57,19 +75,35 @@ func (i *Interval[N]) Predecessors(node *graph.Node[N]) []*graph.Node[N] {
 	return preds
 }
 
+// Verify checks that the interval is single-entry, i.e. that every node of the
+// interval other than the header has all of its predecessors in the interval.
+// The error names the first node, in the order of Nodes, with a predecessor
+// outside of the interval.
+func (i *Interval[N]) Verify() error {
+	for _, node := range i.Nodes() {
+		if node.ID() == i.head.ID() {
+			continue
+		}
+		for _, pred := range i.graph.Predecessors(node) {
+			if !i.Contains(pred) {
+				return fmt.Errorf("interval %v is not single-entry: node %v has predecessor %v outside of the interval", i, node, pred)
+			}
+		}
+	}
+	return nil
+}
+
 // String returns a string representation of the interval.
 func (i *Interval[N]) String() string {
 	var b strings.Builder
 	b.WriteString("I(")
 	b.WriteString(i.head.String())
 	b.WriteString(") {")
-	idx := 0
-	for _, node := range i.nodes {
+	for idx, node := range i.Nodes() {
 		if idx > 0 {
 			b.WriteString(",")
 		}
 		b.WriteString(node.String())
-		idx++
 	}
 	b.WriteString("}")
 	return b.String()
@@ -125,7 +159,7 @@ func Intervals[N comparable](g *graph.Graph[N]) []*Interval[N] {
 // with all immediate predecessors in the interval.
 func findNodeWithImmediatePredecessorsInInterval[N comparable](g *graph.Graph[N], interval *Interval[N]) (*graph.Node[N], bool) {
 outer:
-	for _, node := range g.Nodes() {
+	for node := range g.AllNodes() {
 		// Skip the root node.
 		if g.Root().ID() == node.ID() {
 			continue
@@ -152,7 +186,7 @@ outer:
 // findUnprocessedNodeWithImmediatePredecessors locates a node not in the interval
 // nor in the headers that has at least one immediate predecessor in the interval.
 func findUnprocessedNodeWithImmediatePredecessors[N comparable](g *graph.Graph[N], interval *Interval[N], headers *queue[N]) (*graph.Node[N], bool) {
-	for _, node := range g.Nodes() {
+	for node := range g.AllNodes() {
 		// Skip nodes already in the interval.
 		if interval.Contains(node) {
 			continue
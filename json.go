@@ -0,0 +1,76 @@
+package decompile
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// primitiveJSON is the JSON representation of a primitive, in which the kind
+// is given by its name rather than its numeric value.
+type primitiveJSON[N comparable] struct {
+	Kind          string       `json:"kind"`
+	Entry         N            `json:"entry"`
+	Exit          N            `json:"exit"`
+	Body          []N          `json:"body"`
+	Extra         map[string]N `json:"extra"`
+	AbnormalExits []N          `json:"abnormalExits,omitempty"`
+}
+
+// MarshalJSON encodes the primitive as a JSON object with the name of its kind
+// and its node values, which must be JSON-serializable. The parent of the
+// primitive is not encoded. User-defined kinds must be registered by
+// RegisterPrimitiveKind.
+func (p Primitive[N]) MarshalJSON() ([]byte, error) {
+	name := p.Kind.String()
+	if _, ok := parsePrimitiveKind(name); !ok {
+		return nil, fmt.Errorf("unregistered primitive kind %d", p.Kind)
+	}
+	return json.Marshal(primitiveJSON[N]{
+		Kind:          name,
+		Entry:         p.Entry,
+		Exit:          p.Exit,
+		Body:          p.Body,
+		Extra:         p.Extra,
+		AbnormalExits: p.AbnormalExits,
+	})
+}
+
+// UnmarshalJSON decodes a primitive encoded by MarshalJSON. The parent of the
+// decoded primitive is nil.
+func (p *Primitive[N]) UnmarshalJSON(data []byte) error {
+	var v primitiveJSON[N]
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	kind, ok := parsePrimitiveKind(v.Kind)
+	if !ok {
+		return fmt.Errorf("unknown primitive kind %q", v.Kind)
+	}
+	*p = Primitive[N]{
+		Kind:          kind,
+		Entry:         v.Entry,
+		Exit:          v.Exit,
+		Body:          v.Body,
+		Extra:         v.Extra,
+		AbnormalExits: v.AbnormalExits,
+	}
+	return nil
+}
+
+// parsePrimitiveKind returns the primitive kind with the given name, either
+// built-in or registered by RegisterPrimitiveKind.
+func parsePrimitiveKind(name string) (PrimitiveKind, bool) {
+	for kind := range UserPrimitiveKind {
+		if s := kind.String(); s == name && s != "Unknown" {
+			return kind, true
+		}
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	for kind, s := range kindNames {
+		if s == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package decompile
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+// Layout returns a block ordering of the given control flow graph which
+// respects the structure of the given primitive tree, placing every reachable
+// block once. The blocks are placed in the order in which Emit emits them: a
+// loop body is contiguous, the then-branch of a conditional is placed before
+// its else-branch, and the follow of a primitive after it.
+//
+// The graph is a parameter since a block ordering must place every block,
+// while the primitive tree omits the blocks of conditional arms and those
+// outside of any primitive; Layout(tree) alone placed only primitive entries
+// and follows.
+func Layout[N comparable](g *graph.Graph[N], tree *PrimitiveTree[N]) []N {
+	return layout(g, tree, g.Successors)
+}
+
+// LayoutWeighted returns a block ordering like Layout, but uses the edge
+// weights of the given graph to place the hottest successor of each block
+// first, such that it directly follows the block where the structure allows
+// (e.g. the hotter branch of a conditional becomes its then-branch). Without
+// edge weights it is identical to Layout.
+func LayoutWeighted[N comparable](g *graph.Graph[N], tree *PrimitiveTree[N]) []N {
+	return layout(g, tree, func(n *graph.Node[N]) []*graph.Node[N] {
+		return hotOrder(g, n)
+	})
+}
+
+// layout returns a block ordering of the given control flow graph and
+// primitive tree, visiting the successors of each block in the order returned
+// by successors.
+func layout[N comparable](g *graph.Graph[N], tree *PrimitiveTree[N], successors func(n *graph.Node[N]) []*graph.Node[N]) []N {
+	e := newEmitter(g, tree, nil, successors)
+	e.run()
+	return e.order
+}
+
+// hotOrder returns the successors of the given node ordered by decreasing
+// weight of the edge to them. Successors which are not reached through a
+// weighted edge come last, keeping their original relative order.
+func hotOrder[N comparable](g *graph.Graph[N], n *graph.Node[N]) []*graph.Node[N] {
+	succs := slices.Clone(g.Successors(n))
+	weight := func(succ *graph.Node[N]) float64 {
+		if w, ok := g.EdgeWeight(n, succ); ok {
+			return w
+		}
+		return math.Inf(-1)
+	}
+	slices.SortStableFunc(succs, func(a, b *graph.Node[N]) int {
+		return cmp.Compare(weight(b), weight(a))
+	})
+	return succs
+}
@@ -0,0 +1,365 @@
+package decompile
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// LoopConditionBlocks returns the blocks which collectively compute the
+// condition of the given loop primitive, in evaluation order.
+//
+// For pre-tested loops the condition starts at the loop header, and for
+// post-tested loops it ends at the latch node. Compound (short-circuit)
+// conditions span several blocks, all of which are returned. The condition of
+// a conditional self-loop is its single node. Endless loops have no condition
+// and nil is returned.
+func LoopConditionBlocks[N comparable](g *graph.Graph[N], prim Primitive[N]) []N {
+	body := bodySet(prim)
+	inLoop := func(n *graph.Node[N]) bool {
+		_, ok := body[n.Value]
+		return ok && n.Kind == graph.DefaultNode
+	}
+
+	switch prim.Kind {
+	case PreTestedLoop:
+		head, ok := g.GetNode(prim.Entry)
+		if !ok {
+			return nil
+		}
+		blocks := []N{head.Value}
+		for n := head; ; {
+			next, ok := compoundSuccessor(g, n)
+			if !ok || !inLoop(next) || next.ID() == head.ID() {
+				break
+			}
+			blocks = append(blocks, next.Value)
+			n = next
+		}
+		return blocks
+
+	case PostTestedLoop:
+		value, ok := prim.Extra["latch"]
+		if !ok {
+			return nil
+		}
+		latch, ok := g.GetNode(value)
+		if !ok {
+			return nil
+		}
+		blocks := []N{latch.Value}
+		for n := latch; n.Value != prim.Entry; {
+			prev, ok := compoundPredecessor(g, n)
+			if !ok || !inLoop(prev) || prev.ID() == latch.ID() {
+				break
+			}
+			blocks = append([]N{prev.Value}, blocks...)
+			n = prev
+		}
+		return blocks
+
+	case SelfLoop:
+		if _, ok := prim.Extra["cond"]; ok {
+			return []N{prim.Entry}
+		}
+	}
+
+	return nil
+}
+
+// LoopUpdateBlock returns the update block of the given pre-tested loop, i.e.
+// the block executed once per iteration just before control returns to the
+// loop header. It is the unique predecessor of the header within the loop
+// body, provided that it is a dedicated block: distinct from the header, not
+// entered directly from the header and unconditionally branching back to it.
+// Such a block forms the update clause of a reconstructed for-loop. The
+// boolean return value is false if no update block exists (e.g. a while-loop
+// whose body branches straight back to the header).
+func LoopUpdateBlock[N comparable](g *graph.Graph[N], prim Primitive[N]) (N, bool) {
+	var zero N
+	if prim.Kind != PreTestedLoop {
+		return zero, false
+	}
+	head, ok := g.GetNode(prim.Entry)
+	if !ok {
+		return zero, false
+	}
+	body := bodySet(prim)
+	var update *graph.Node[N]
+	for _, pred := range g.Predecessors(head) {
+		if _, ok := body[pred.Value]; !ok {
+			continue
+		}
+		if update != nil {
+			// Multiple back edges to the header.
+			return zero, false
+		}
+		update = pred
+	}
+	if update == nil || update.ID() == head.ID() || g.OutDegree(update) != 1 || g.HasEdge(head, update) {
+		return zero, false
+	}
+	return update.Value, true
+}
+
+// LoopInvariantBlocks returns the blocks of the body of the given loop whose
+// only predecessor within the loop is the loop header, i.e. which are entered
+// from the header and from no other block of the loop. Such blocks are
+// structural candidates for hoisting into the preheader; whether their
+// computations are actually loop-invariant is up to data-flow analysis.
+func LoopInvariantBlocks[N comparable](g *graph.Graph[N], prim Primitive[N]) []N {
+	blocks := make([]N, 0)
+	body := bodySet(prim)
+outer:
+	for _, value := range prim.Body {
+		node, ok := g.GetNode(value)
+		if !ok || value == prim.Entry {
+			continue
+		}
+		fromHeader := false
+		for _, pred := range g.Predecessors(node) {
+			if pred.Value == prim.Entry {
+				fromHeader = true
+			} else if _, ok := body[pred.Value]; ok {
+				continue outer
+			}
+		}
+		if fromHeader {
+			blocks = append(blocks, value)
+		}
+	}
+	return blocks
+}
+
+// LoopExitAmbiguities returns the exit blocks of the given loop which have
+// predecessors both inside and outside of the loop, in ascending order. Such a
+// block is shared between the loop and unrelated code, so attributing it to
+// the loop is ambiguous, and it is a candidate for duplication before
+// emission.
+func LoopExitAmbiguities[N comparable](g *graph.Graph[N], prim Primitive[N]) []N {
+	switch prim.Kind {
+	case PreTestedLoop, PostTestedLoop, EndlessLoop, ConditionalEndlessLoop, SelfLoop:
+	default:
+		return nil
+	}
+	body := bodySet(prim)
+	exits := make([]*graph.Node[N], 0)
+	seen := make(map[graph.ID[N]]struct{})
+	for value := range body {
+		node, ok := g.GetNode(value)
+		if !ok {
+			continue
+		}
+		for _, succ := range g.Successors(node) {
+			if _, ok := body[succ.Value]; ok {
+				continue
+			}
+			if _, ok := seen[succ.ID()]; ok {
+				continue
+			}
+			seen[succ.ID()] = struct{}{}
+			for _, pred := range g.Predecessors(succ) {
+				if _, ok := body[pred.Value]; !ok {
+					exits = append(exits, succ)
+					break
+				}
+			}
+		}
+	}
+	blocks := make([]N, 0, len(exits))
+	for _, exit := range ascReversePostOrder(exits) {
+		blocks = append(blocks, exit.Value)
+	}
+	return blocks
+}
+
+// ExitEdgeContext returns, for each source block of an edge leaving the given
+// loop, the entry of the innermost conditional primitive within the loop whose
+// region contains the block, or the entry of the loop itself if the block is
+// directly in the loop body. This determines the conditional arm in which the
+// break corresponding to the exit edge is emitted.
+//
+// The graph is required since primitives record neither the edges of their
+// blocks nor the arms of a 2-way conditional which merge at its follow, so
+// both the exit edges and the regions of the conditionals are recovered from
+// it.
+func ExitEdgeContext[N comparable](g *graph.Graph[N], prims []Primitive[N], loop Primitive[N]) map[N]N {
+	body := bodySet(loop)
+	// Compute the regions of the conditionals nested within the loop,
+	// restricted to the loop body.
+	conds := make([]Primitive[N], 0)
+	regions := make([]map[N]struct{}, 0)
+	for _, prim := range prims {
+		if prim.Kind != TwoWayConditional && prim.Kind != NWayConditional {
+			continue
+		}
+		if _, ok := body[prim.Entry]; !ok {
+			continue
+		}
+		nodes := region(g, prim)
+		for value := range nodes {
+			if _, ok := body[value]; !ok {
+				delete(nodes, value)
+			}
+		}
+		conds = append(conds, prim)
+		regions = append(regions, nodes)
+	}
+	context := make(map[N]N)
+	for value := range body {
+		node, ok := g.GetNode(value)
+		if !ok {
+			continue
+		}
+		exits := slices.ContainsFunc(g.Successors(node), func(succ *graph.Node[N]) bool {
+			_, ok := body[succ.Value]
+			return !ok
+		})
+		if !exits {
+			continue
+		}
+		context[value] = loop.Entry
+		innermost := -1
+		for i := range conds {
+			if _, ok := regions[i][value]; !ok {
+				continue
+			}
+			if innermost == -1 || len(regions[i]) < len(regions[innermost]) {
+				innermost = i
+			}
+		}
+		if innermost != -1 {
+			context[value] = conds[innermost].Entry
+		}
+	}
+	return context
+}
+
+// LoopDominatedExterior returns the blocks dominated by the header of the given
+// loop which are not part of the loop body, in ascending order. Such blocks
+// are only reachable through the loop, e.g. as the landing pads of breaks, as
+// opposed to code after the loop which is also reachable without passing the
+// loop header.
+func LoopDominatedExterior[N comparable](g *graph.Graph[N], dom *dominator.Tree[N], prim Primitive[N]) []N {
+	head, ok := g.GetNode(prim.Entry)
+	if !ok {
+		return nil
+	}
+	body := bodySet(prim)
+	blocks := make([]N, 0)
+	for _, node := range ascReversePostOrder(dominatedSubtree(dom, head)) {
+		if _, ok := body[node.Value]; !ok {
+			blocks = append(blocks, node.Value)
+		}
+	}
+	return blocks
+}
+
+// SCC returns the strongly connected components of the given graph, computed
+// by Tarjan's algorithm, largest first. Components of equal size are in the
+// order in which they are completed. A component with several nodes contains a
+// loop; if it is entered at more than one node, the loop is irreducible.
+func SCC[N comparable](g *graph.Graph[N]) [][]*graph.Node[N] {
+	sccs := g.Components()
+	slices.SortStableFunc(sccs, func(a, b []*graph.Node[N]) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	return sccs
+}
+
+// NaturalLoop returns the natural loop of the back edge from the given latch to
+// the given header, i.e. the header and every node from which the latch is
+// reachable without passing through the header. The header comes first,
+// followed by the other nodes in ascending order. Unlike the loops found by
+// StructureLoops, the natural loop is independent of intervals and the node
+// ordering.
+func NaturalLoop[N comparable](g *graph.Graph[N], header, latch *graph.Node[N]) []*graph.Node[N] {
+	visited := map[graph.ID[N]]bool{header.ID(): true}
+	nodes := make([]*graph.Node[N], 0)
+	work := newStack[N]()
+	if !visited[latch.ID()] {
+		visited[latch.ID()] = true
+		work.push(latch)
+	}
+	for !work.empty() {
+		n := work.pop()
+		nodes = append(nodes, n)
+		for _, pred := range g.Predecessors(n) {
+			if !visited[pred.ID()] {
+				visited[pred.ID()] = true
+				work.push(pred)
+			}
+		}
+	}
+	return append([]*graph.Node[N]{header}, ascReversePostOrder(nodes)...)
+}
+
+// LoopHeaders returns the headers of the natural loops of the given graph, i.e.
+// the nodes which dominate one of their predecessors (including nodes with an
+// edge to themselves), in ascending order. It requires neither the derived
+// sequence of graphs nor a full structuring run. For reducible graphs, the
+// headers are the nodes which StructureLoops marks as loop headers, except for
+// the headers of loops sharing their latch with a nested loop, which are not
+// structured.
+func LoopHeaders[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) []*graph.Node[N] {
+	headers := make([]*graph.Node[N], 0)
+	for node := range g.AllNodes() {
+		if slices.ContainsFunc(g.Predecessors(node), func(pred *graph.Node[N]) bool {
+			return dom.Dominates(node, pred)
+		}) {
+			headers = append(headers, node)
+		}
+	}
+	return ascReversePostOrder(headers)
+}
+
+// LoopExits returns the break and continue edges of the given loop primitive as
+// (source, target) pairs, in ascending order of their sources.
+//
+// Breaks are the edges from body nodes to the follow of the loop, except for
+// the edge of the node controlling the loop, i.e. the header of a pre-tested
+// loop and the latch of a post-tested loop. Several body nodes may break to the
+// follow, which is the target of every break. Continues are the back edges to
+// the loop header from body nodes other than the latch, whose back edge is the
+// natural end of an iteration, even if it is conditional.
+func LoopExits[N comparable](g *graph.Graph[N], prim Primitive[N]) (breaks, continues [][2]N) {
+	breaks, continues = make([][2]N, 0), make([][2]N, 0)
+	head, ok := g.GetNode(prim.Entry)
+	if !ok {
+		return breaks, continues
+	}
+	var control *graph.Node[N]
+	switch prim.Kind {
+	case PreTestedLoop, SelfLoop:
+		control = head
+	case PostTestedLoop:
+		if latch, ok := prim.Extra["latch"]; ok {
+			control, _ = g.GetNode(latch)
+		}
+	case EndlessLoop, ConditionalEndlessLoop:
+	default:
+		return breaks, continues
+	}
+	var follow *graph.Node[N]
+	if value, ok := prim.Extra["follow"]; ok {
+		follow, _ = g.GetNode(value)
+	}
+	latch, hasLatch := prim.Extra["latch"]
+	nodes := make([]*graph.Node[N], 0, len(prim.Body))
+	for value := range bodySet(prim) {
+		if node, ok := g.GetNode(value); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	for _, node := range ascReversePostOrder(nodes) {
+		if follow != nil && g.HasEdge(node, follow) && node != control {
+			breaks = append(breaks, [2]N{node.Value, follow.Value})
+		}
+		if g.HasEdge(node, head) && !(hasLatch && node.Value == latch) {
+			continues = append(continues, [2]N{node.Value, head.Value})
+		}
+	}
+	return breaks, continues
+}
@@ -0,0 +1,67 @@
+package decompile
+
+import "github.com/nukilabs/decompile/graph"
+
+// Options configures the structuring of control flow graphs.
+type Options[N comparable] struct {
+	// EmitUnstructured emits a primitive of kind None for each loop or
+	// conditional which cannot be structured, capturing the nodes of its region,
+	// rather than omitting it. This allows falling back to goto-based emission
+	// with full information about the region.
+	EmitUnstructured bool
+	// OrderSuccessors, if set, orders the successors of the given node, e.g.
+	// such that the fall-through successor comes first and the jump target
+	// second. The order determines the assignment of branches during
+	// structuring, such as which successor of a loop header is the follow. The
+	// returned slice must be a permutation of the given successors; otherwise,
+	// the successors are used in their default order.
+	OrderSuccessors func(from N, succs []N) []N
+	// FollowStrategy determines how the follow nodes of 2-way conditionals are
+	// selected.
+	FollowStrategy FollowStrategy
+}
+
+// FollowStrategy is a strategy for selecting the follow node of a 2-way
+// conditional among the nodes immediately dominated by the conditional node
+// which have at least two predecessors.
+type FollowStrategy int
+
+// Follow strategies.
+const (
+	// FollowHighestOrder selects the candidate with the highest order, i.e.
+	// the last merge node dominated by the conditional node.
+	FollowHighestOrder FollowStrategy = iota
+	// FollowBFS selects the candidate first reached by a breadth-first search
+	// from the conditional node, i.e. the nearest merge node. Ties are broken
+	// by the lower order.
+	FollowBFS
+)
+
+// successors returns the successors of the given node, ordered by the
+// OrderSuccessors callback if set.
+func (opts Options[N]) successors(g *graph.Graph[N], n *graph.Node[N]) []*graph.Node[N] {
+	succs := g.Successors(n)
+	if opts.OrderSuccessors == nil || len(succs) < 2 {
+		return succs
+	}
+	byValue := make(map[N]*graph.Node[N], len(succs))
+	values := make([]N, 0, len(succs))
+	for _, succ := range succs {
+		byValue[succ.Value] = succ
+		values = append(values, succ.Value)
+	}
+	ordered := make([]*graph.Node[N], 0, len(succs))
+	for _, value := range opts.OrderSuccessors(n.Value, values) {
+		succ, ok := byValue[value]
+		if !ok {
+			return succs
+		}
+		// Remove the successor such that duplicates are detected.
+		delete(byValue, value)
+		ordered = append(ordered, succ)
+	}
+	if len(ordered) != len(succs) {
+		return succs
+	}
+	return ordered
+}
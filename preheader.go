@@ -0,0 +1,76 @@
+package decompile
+
+import (
+	"slices"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// RedirectPreheaderContinues normalizes loops whose continue edges target the
+// preheader rather than the header of the loop, as found in obfuscated code.
+// A preheader is a single-entry node which dominates and unconditionally
+// branches to the loop header, and which is also the target of back edges
+// from within the loop. The header must also be the target of a back edge
+// from within the loop, such that an ordinary loop whose first node branches
+// unconditionally to the next is left alone. Each back edge to the preheader
+// is redirected to the loop header, such that the loop structures normally.
+//
+// The graph is modified in place and must be reanalyzed before structuring.
+// The redirected edges are returned as a mapping from the original edge
+// (source, preheader) to the header it now targets. Since the redirected edges
+// bypass the preheader, its contents (if any) must be emitted by the caller at
+// the end of each such continue.
+func RedirectPreheaderContinues[N comparable](g *graph.Graph[N]) map[[2]N]N {
+	redirected := make(map[[2]N]N)
+	g.InitOrder()
+	dom := dominator.New(g)
+	for _, pre := range ascReversePostOrder(g.Nodes()) {
+		succs := g.Successors(pre)
+		if len(succs) != 1 || succs[0].ID() == pre.ID() {
+			continue
+		}
+		head := succs[0]
+		if !dom.Dominates(pre, head) {
+			continue
+		}
+		// The preheader must have a single entry, all other predecessors being
+		// back edges from within the loop.
+		var entries int
+		latches := make([]*graph.Node[N], 0)
+		for _, pred := range ascReversePostOrder(g.Predecessors(pre)) {
+			if dom.Dominates(pre, pred) {
+				latches = append(latches, pred)
+			} else {
+				entries++
+			}
+		}
+		if entries != 1 || len(latches) == 0 {
+			continue
+		}
+		// The header must itself be the target of a back edge from within the
+		// loop, such that the back edges to the preheader are additional
+		// continues rather than the only latch of an ordinary loop (e.g. of a
+		// do-while loop whose first node is the preheader).
+		if !slices.ContainsFunc(g.Predecessors(head), func(pred *graph.Node[N]) bool {
+			return pred.ID() != pre.ID() && dom.Dominates(head, pred)
+		}) {
+			continue
+		}
+		if slices.ContainsFunc(latches, func(latch *graph.Node[N]) bool {
+			return !dom.Dominates(head, latch)
+		}) {
+			continue
+		}
+		for _, latch := range latches {
+			w, hasWeight := g.EdgeWeight(latch, pre)
+			g.RemoveEdge(latch, pre)
+			g.SetEdge(latch, head)
+			if hasWeight {
+				g.SetEdgeWeight(latch, head, w)
+			}
+			redirected[[2]N{latch.Value, pre.Value}] = head.Value
+		}
+	}
+	return redirected
+}
@@ -1,5 +1,15 @@
 package decompile
 
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
 type PrimitiveKind uint8
 
 const (
@@ -8,6 +18,24 @@ const (
 	PostTestedLoop
 	EndlessLoop
 	TwoWayConditional
+	NWayConditional
+	// ConditionalEndlessLoop is an endless loop which is left by a break, i.e.
+	// while (true) { ...; if (c) break; }, as opposed to an EndlessLoop which
+	// has no exit at all.
+	ConditionalEndlessLoop
+	// GuardLadder is a chain of single-armed conditionals, each of which exits
+	// early through a sink (e.g. if (bad) return;), on the fall-through path.
+	GuardLadder
+	// ShortCircuitConditional is a compound condition of two chained 2-way
+	// nodes sharing a branch target, i.e. if (a && b) or if (a || b).
+	ShortCircuitConditional
+	// SelfLoop is a loop consisting of a single node with an edge to itself,
+	// i.e. do {} while (c) if the self-edge is conditional, or for {} if it is
+	// the only successor of the node.
+	SelfLoop
+	// UnresolvedConditional is a 2-way conditional for which no follow node
+	// was found, such that it cannot be emitted as a structured if statement.
+	UnresolvedConditional
 )
 
 func (k PrimitiveKind) String() string {
@@ -22,7 +50,24 @@ func (k PrimitiveKind) String() string {
 		return "EndlessLoop"
 	case TwoWayConditional:
 		return "TwoWayConditional"
+	case NWayConditional:
+		return "NWayConditional"
+	case ConditionalEndlessLoop:
+		return "ConditionalEndlessLoop"
+	case GuardLadder:
+		return "GuardLadder"
+	case ShortCircuitConditional:
+		return "ShortCircuitConditional"
+	case SelfLoop:
+		return "SelfLoop"
+	case UnresolvedConditional:
+		return "UnresolvedConditional"
 	default:
+		registry.RLock()
+		defer registry.RUnlock()
+		if name, ok := kindNames[k]; ok {
+			return name
+		}
 		return "Unknown"
 	}
 }
@@ -33,4 +78,112 @@ type Primitive[N comparable] struct {
 	Body  []N
 	Exit  N
 	Extra map[string]N
+	// Parent is the immediately enclosing primitive, i.e. the innermost
+	// primitive whose body contains the entry of this primitive, or nil if
+	// there is none. It is populated by Structure and points into the slice of
+	// primitives returned by it.
+	Parent *Primitive[N]
+	// AbnormalExits holds the body nodes of a loop with a successor outside of
+	// the loop other than its follow, in ascending order. Such exits do not
+	// continue at the follow, and are emitted as a break to a label or a goto.
+	AbnormalExits []N
+}
+
+// String returns a one-line representation of the primitive, listing its kind,
+// entry, exit (unless zero or the follow), extra nodes sorted by key and body,
+// e.g. PreTestedLoop(entry=2, follow=5, latch=4, body=[3 4]).
+func (p Primitive[N]) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v(entry=%v", p.Kind, p.Entry)
+	var zero N
+	if follow, ok := p.Extra["follow"]; p.Exit != zero && (!ok || follow != p.Exit) {
+		fmt.Fprintf(&b, ", exit=%v", p.Exit)
+	}
+	for _, key := range slices.Sorted(maps.Keys(p.Extra)) {
+		fmt.Fprintf(&b, ", %s=%v", key, p.Extra[key])
+	}
+	fmt.Fprintf(&b, ", body=%v)", p.Body)
+	return b.String()
+}
+
+// ComputeFollow computes the follow node of the primitive from the given
+// post-dominator tree of the control flow graph. The follow of a loop is the
+// first post-dominator of the loop header outside the loop body, and the
+// follow of a conditional is the immediate post-dominator of the conditional
+// node. The boolean return value indicates success, and is false if the
+// follow is a virtual node of the post-dominator tree (e.g. a synthetic exit).
+func (p Primitive[N]) ComputeFollow(g *graph.Graph[N], pdom *dominator.Tree[N]) (N, bool) {
+	var zero N
+	entry, ok := g.GetNode(p.Entry)
+	if !ok {
+		return zero, false
+	}
+	follow := pdom.DominatorOf(entry)
+	switch p.Kind {
+	case PreTestedLoop, PostTestedLoop, EndlessLoop, ConditionalEndlessLoop, SelfLoop:
+		body := bodySet(p)
+		for follow != nil {
+			if _, ok := body[follow.Value]; !ok || follow.Kind != graph.DefaultNode {
+				break
+			}
+			follow = pdom.DominatorOf(follow)
+		}
+	}
+	if follow == nil || follow.Kind != graph.DefaultNode {
+		return zero, false
+	}
+	return follow.Value, true
+}
+
+// MayExecuteZeroTimes reports whether the body of the given loop primitive may
+// be skipped entirely, which is only the case for pre-tested loops. The bodies
+// of post-tested loops and endless loops (with or without a break) execute at
+// least once. It returns false for primitives other than loops.
+func (p Primitive[N]) MayExecuteZeroTimes() bool {
+	return p.Kind == PreTestedLoop
+}
+
+// Follows returns the follow node of each of the given primitives which has
+// one, in the order of the primitives. The same node is returned once for each
+// primitive it is the follow of, which allows detecting follow collisions.
+func Follows[N comparable](prims []Primitive[N]) []N {
+	follows := make([]N, 0, len(prims))
+	for _, prim := range prims {
+		if follow, ok := prim.Extra["follow"]; ok {
+			follows = append(follows, follow)
+		}
+	}
+	return follows
+}
+
+// UncoveredNodes returns the nodes reachable from the root of the given graph
+// which are covered by none of the given primitives, in ascending order. A
+// primitive covers the nodes of its region (see AbnormalEntries), i.e. its
+// entry and body, or for conditionals every node up to the follow, as well as
+// its follow node. Uncovered nodes are straight-line code between primitives
+// and remnants which could not be structured.
+func UncoveredNodes[N comparable](g *graph.Graph[N], prims []Primitive[N]) []N {
+	if g.Root() == nil {
+		return nil
+	}
+	covered := make(map[N]struct{})
+	for _, prim := range prims {
+		for value := range region(g, prim) {
+			covered[value] = struct{}{}
+		}
+		if follow, ok := prim.Extra["follow"]; ok {
+			covered[follow] = struct{}{}
+		}
+	}
+	nodes := make([]*graph.Node[N], 0)
+	for node := range g.ReachableFrom(g.Root()) {
+		if _, ok := covered[node.Value]; !ok {
+			nodes = append(nodes, node)
+		}
+	}
+	uncovered := make([]N, 0, len(nodes))
+	for _, node := range ascReversePostOrder(nodes) {
+		uncovered = append(uncovered, node.Value)
+	}
+	return uncovered
 }
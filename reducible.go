@@ -0,0 +1,180 @@
+package decompile
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+// maxSplitGrowth bounds the size of the graph produced by node splitting, as a
+// multiple of the size of the original graph, since node splitting may grow
+// the graph exponentially in the worst case.
+const maxSplitGrowth = 16
+
+// ErrIrreducible is returned (wrapped) by Structure if the control flow graph
+// is irreducible, in which case loops involving the offending nodes are not
+// structured reliably.
+var ErrIrreducible = errors.New("irreducible control flow graph")
+
+// IsReducible reports whether the given control flow graph is reducible, i.e.
+// whether its derived sequence of graphs collapses into a single node.
+func IsReducible[N comparable](g *graph.Graph[N]) bool {
+	return len(irreducibleNodes(g)) == 0
+}
+
+// irreducibleNodes returns the nodes of the original control flow graph which
+// head the intervals on cycles of the limit graph of the derived sequence, in
+// ascending order. These are the entries of the irreducible loops of the
+// graph; the result is empty if the graph is reducible.
+func irreducibleNodes[N comparable](g *graph.Graph[N]) []*graph.Node[N] {
+	if !g.HasCycle() {
+		return nil
+	}
+	graphs, intervals, _ := DerivedSequence(g)
+	return limitEntries(g, graphs, intervals)
+}
+
+// limitEntries returns the nodes of the original control flow graph which head
+// the intervals on cycles of the limit graph of the given derived sequence of
+// graphs, as by irreducibleNodes. The result is empty if the sequence is.
+func limitEntries[N comparable](g *graph.Graph[N], graphs []*graph.Graph[N], intervals [][]*Interval[N]) []*graph.Node[N] {
+	if len(graphs) == 0 {
+		return nil
+	}
+	limit := graphs[len(graphs)-1]
+	if limit.Len() <= 1 {
+		return nil
+	}
+	var iis []*Interval[N]
+	for _, i := range intervals {
+		iis = append(iis, i...)
+	}
+	nodes := make([]*graph.Node[N], 0)
+	for _, scc := range limit.Components() {
+		if len(scc) < 2 {
+			continue
+		}
+		for _, n := range scc {
+			head := findOrigHead(n, iis)
+			if orig, ok := g.GetNode(head.Value); ok && head.Kind == graph.DefaultNode {
+				nodes = append(nodes, orig)
+			}
+		}
+	}
+	return ascReversePostOrder(nodes)
+}
+
+// MakeReducible applies controlled node splitting to the given control flow
+// graph until it is reducible, and returns the resulting graph. The given graph
+// is not modified.
+//
+// Each step picks a node of the limit graph of the derived sequence which is on
+// a cycle and has several predecessors, and duplicates the region of the
+// original graph collapsed into it (a single-entry region) once for every
+// predecessor but the first, redirecting the edges of each such predecessor to
+// its own copy. Copies are split nodes (see graph.Graph.Split) carrying the
+// value of the copied node. The returned map holds, for the ID of each default
+// node which has been copied, the IDs of its copies in the resulting graph,
+// such that the caller may recombine the duplicated blocks during emission.
+//
+// An error is returned if the graph has no root, or if the resulting graph
+// would exceed a size limit.
+func MakeReducible[N comparable](g *graph.Graph[N]) (*graph.Graph[N], map[graph.ID[N]][]graph.ID[N], error) {
+	if g.Root() == nil {
+		return nil, nil, errors.New("unable to make graph without root reducible")
+	}
+	c := g.Clone()
+	splits := make(map[graph.ID[N]][]graph.ID[N])
+	for !IsReducible(c) {
+		if c.Len() > maxSplitGrowth*g.Len() {
+			return nil, nil, fmt.Errorf("node splitting exceeded %d nodes", maxSplitGrowth*g.Len())
+		}
+		c.InitOrder()
+		graphs, intervals, _ := DerivedSequence(c)
+		var iis []*Interval[N]
+		for _, i := range intervals {
+			iis = append(iis, i...)
+		}
+		limit := graphs[len(graphs)-1]
+		n, ok := splitCandidate(limit, iis)
+		if !ok {
+			return nil, nil, errors.New("unable to locate node to split")
+		}
+		region := ascReversePostOrder(collapsedNodes(n, iis))
+		head := findOrigHead(n, iis)
+		for _, pred := range limit.Predecessors(n)[1:] {
+			// Redirect the edges entering the region from the predecessor to a
+			// copy of the region.
+			copies := splitRegion(c, region, splits)
+			for _, p := range collapsedNodes(pred, iis) {
+				if c.HasEdge(p, head) {
+					c.RemoveEdge(p, head)
+					c.SetEdge(p, copies[head])
+				}
+			}
+		}
+	}
+	return c, splits, nil
+}
+
+// splitCandidate returns the node on a cycle of the given limit graph which has
+// several predecessors and whose header in the original control flow graph
+// comes first in reverse postorder. The boolean return value indicates
+// success.
+func splitCandidate[N comparable](limit *graph.Graph[N], intervals []*Interval[N]) (*graph.Node[N], bool) {
+	var cand *graph.Node[N]
+	for _, scc := range limit.Components() {
+		if len(scc) < 2 {
+			continue
+		}
+		for _, n := range scc {
+			if n.ID() == limit.Root().ID() || limit.InDegree(n) < 2 {
+				continue
+			}
+			if cand == nil || findOrigHead(n, intervals).Order < findOrigHead(cand, intervals).Order {
+				cand = n
+			}
+		}
+	}
+	return cand, cand != nil
+}
+
+// collapsedNodes returns the nodes of the original control flow graph which are
+// collapsed into the given node of the derived sequence of graphs.
+func collapsedNodes[N comparable](n *graph.Node[N], intervals []*Interval[N]) []*graph.Node[N] {
+	i, ok := getInterval(n.ID(), intervals)
+	if !ok {
+		return []*graph.Node[N]{n}
+	}
+	nodes := make([]*graph.Node[N], 0)
+	for _, node := range i.Nodes() {
+		nodes = append(nodes, collapsedNodes(node, intervals)...)
+	}
+	return nodes
+}
+
+// splitRegion adds a copy of each of the given nodes to the graph, along with
+// copies of their outgoing edges (and weights), and records the copies in
+// splits. It returns the mapping from the given nodes to their copies.
+func splitRegion[N comparable](g *graph.Graph[N], region []*graph.Node[N], splits map[graph.ID[N]][]graph.ID[N]) map[*graph.Node[N]]*graph.Node[N] {
+	copies := make(map[*graph.Node[N]]*graph.Node[N], len(region))
+	for _, n := range region {
+		orig := graph.ID[N]{Kind: graph.DefaultNode, Value: n.Value}
+		copies[n] = g.Split(n.Value, len(splits[orig])+1)
+		splits[orig] = append(splits[orig], copies[n].ID())
+	}
+	for _, n := range region {
+		for _, succ := range g.Successors(n) {
+			to := succ
+			if c, ok := copies[succ]; ok {
+				to = c
+			}
+			g.SetEdge(copies[n], to)
+			if w, ok := g.EdgeWeight(n, succ); ok {
+				g.SetEdgeWeight(copies[n], to, w)
+			}
+		}
+	}
+	return copies
+}
@@ -0,0 +1,420 @@
+package decompile
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/nukilabs/decompile/graph"
+)
+
+// RegionNode is a node in the program structure tree of a control flow graph,
+// representing a canonical single-entry single-exit (SESE) region. The root of
+// the tree represents the entire graph.
+type RegionNode[N comparable] struct {
+	// Entry is the entry edge of the region. A nil end denotes the virtual
+	// entry or exit node of the graph. Both ends are nil for the root.
+	Entry [2]*graph.Node[N]
+	// Exit is the exit edge of the region. A nil end denotes the virtual entry
+	// or exit node of the graph. Both ends are nil for the root.
+	Exit [2]*graph.Node[N]
+	// Nodes holds the nodes of the region, including the nodes of nested
+	// regions, in depth-first order.
+	Nodes []*graph.Node[N]
+	// Children holds the regions nested directly within the region.
+	Children []*RegionNode[N]
+}
+
+// Regions computes the program structure tree of the given control flow graph,
+// i.e. its decomposition into nested canonical single-entry single-exit
+// regions, using the cycle equivalence algorithm of Johnson, Pearson and
+// Pingali. Only nodes reachable from the root are considered.
+func Regions[N comparable](g *graph.Graph[N]) *RegionNode[N] {
+	root := &RegionNode[N]{}
+	if g.Root() == nil {
+		return root
+	}
+
+	// Number the nodes reachable from the root in depth-first order. The first
+	// two indices are reserved for the virtual entry and exit nodes.
+	p := &pst[N]{nodes: []*graph.Node[N]{nil, nil}, index: make(map[graph.ID[N]]int)}
+	g.DFS(func(n *graph.Node[N]) {
+		p.index[n.ID()] = len(p.nodes)
+		p.nodes = append(p.nodes, n)
+	}, nil)
+	root.Nodes = p.nodes[pstFirst:]
+
+	p.augment(g)
+	p.cycleEquivalence()
+	root.Children = nestRegions(p.canonicalRegions(), func(n *graph.Node[N]) int {
+		return p.index[n.ID()]
+	})
+	return root
+}
+
+const (
+	// pstEntry is the index of the virtual entry node.
+	pstEntry = iota
+	// pstExit is the index of the virtual exit node.
+	pstExit
+	// pstFirst is the index of the first node of the graph.
+	pstFirst
+)
+
+// pst holds the state of the program structure tree computation.
+type pst[N comparable] struct {
+	// nodes of the augmented graph, indexed by depth-first number; nil for the
+	// virtual entry and exit nodes.
+	nodes []*graph.Node[N]
+	// index maps nodes of the graph to their index in nodes.
+	index map[graph.ID[N]]int
+	// edges of the augmented graph.
+	edges []pstEdge
+	// succs holds the outgoing edges of each node.
+	succs [][]int
+	// incident holds the incident edges of each node, in both directions.
+	incident [][]int
+	// classes is the number of cycle equivalence classes.
+	classes int
+}
+
+// pstEdge is an edge of the augmented graph.
+type pstEdge struct {
+	from, to int
+	// class is the cycle equivalence class of the edge, or -1 if undefined.
+	class int
+}
+
+// augment builds the augmented graph: a virtual entry node with an edge to
+// the root, edges from every node which cannot otherwise reach the exit to a
+// virtual exit node, and an edge from the virtual exit to the virtual entry.
+// The resulting graph is strongly connected.
+func (p *pst[N]) augment(g *graph.Graph[N]) {
+	p.succs = make([][]int, len(p.nodes))
+	p.incident = make([][]int, len(p.nodes))
+	preds := make([][]int, len(p.nodes))
+	add := func(from, to int) {
+		idx := len(p.edges)
+		p.edges = append(p.edges, pstEdge{from: from, to: to, class: -1})
+		p.succs[from] = append(p.succs[from], idx)
+		preds[to] = append(preds[to], from)
+		p.incident[from] = append(p.incident[from], idx)
+		if from != to {
+			p.incident[to] = append(p.incident[to], idx)
+		}
+	}
+
+	add(pstEntry, pstFirst)
+	for i := pstFirst; i < len(p.nodes); i++ {
+		for _, succ := range g.Successors(p.nodes[i]) {
+			add(i, p.index[succ.ID()])
+		}
+	}
+
+	// Connect sinks to the virtual exit.
+	for i := pstFirst; i < len(p.nodes); i++ {
+		if len(p.succs[i]) == 0 {
+			add(i, pstExit)
+		}
+	}
+
+	// Connect nodes within endless loops to the virtual exit, visiting nodes in
+	// reverse depth-first order such that the deepest node of each endless loop
+	// is connected.
+	reaches := make([]bool, len(p.nodes))
+	var mark func(n int)
+	mark = func(n int) {
+		reaches[n] = true
+		for _, pred := range preds[n] {
+			if !reaches[pred] {
+				mark(pred)
+			}
+		}
+	}
+	mark(pstExit)
+	for i := len(p.nodes) - 1; i >= pstFirst; i-- {
+		if !reaches[i] {
+			add(i, pstExit)
+			mark(i)
+		}
+	}
+	add(pstExit, pstEntry)
+}
+
+// bracket is an element of a bracket list, holding a backedge.
+type bracket struct {
+	edge       int
+	prev, next *bracket
+	// recentSize and recentClass hold the size of the bracket list and the
+	// equivalence class the last time the bracket was the topmost bracket.
+	recentSize  int
+	recentClass int
+}
+
+// bracketList is a doubly linked list of brackets, topmost first.
+type bracketList struct {
+	head, tail *bracket
+	size       int
+}
+
+// push adds a bracket to the top of the list.
+func (l *bracketList) push(b *bracket) {
+	b.prev, b.next = nil, l.head
+	if l.head != nil {
+		l.head.prev = b
+	} else {
+		l.tail = b
+	}
+	l.head = b
+	l.size++
+}
+
+// delete removes a bracket from the list.
+func (l *bracketList) delete(b *bracket) {
+	if b.prev != nil {
+		b.prev.next = b.next
+	} else {
+		l.head = b.next
+	}
+	if b.next != nil {
+		b.next.prev = b.prev
+	} else {
+		l.tail = b.prev
+	}
+	b.prev, b.next = nil, nil
+	l.size--
+}
+
+// concat prepends the brackets of other to the list.
+func (l *bracketList) concat(other *bracketList) {
+	if other.head == nil {
+		return
+	}
+	if l.head == nil {
+		l.tail = other.tail
+	} else {
+		other.tail.next = l.head
+		l.head.prev = other.tail
+	}
+	l.head = other.head
+	l.size += other.size
+}
+
+// cycleEquivalence computes the cycle equivalence classes of the edges of the
+// augmented graph, based on an undirected depth-first search and bracket lists.
+func (p *pst[N]) cycleEquivalence() {
+	n := len(p.nodes)
+	dfsnum := make([]int, n)
+	for i := range dfsnum {
+		dfsnum[i] = -1
+	}
+	order := make([]int, 0, n)
+	parentEdge := make([]int, n)
+	children := make([][]int, n)
+	// backFrom holds the backedges from a node to its ancestors, and backTo the
+	// backedges (including capping backedges) from descendants to a node.
+	backFrom := make([][]*bracket, n)
+	backTo := make([][]*bracket, n)
+
+	var visit func(v, via int)
+	visit = func(v, via int) {
+		dfsnum[v] = len(order)
+		order = append(order, v)
+		parentEdge[v] = via
+		for _, e := range p.incident[v] {
+			edge := p.edges[e]
+			if e == via {
+				continue
+			}
+			if edge.from == edge.to {
+				// A self-loop forms an equivalence class of its own.
+				p.edges[e].class = p.newClass()
+				continue
+			}
+			w := edge.from
+			if w == v {
+				w = edge.to
+			}
+			if dfsnum[w] == -1 {
+				children[v] = append(children[v], w)
+				visit(w, e)
+			} else if dfsnum[w] < dfsnum[v] {
+				b := &bracket{edge: e, recentSize: -1}
+				backFrom[v] = append(backFrom[v], b)
+				backTo[w] = append(backTo[w], b)
+			}
+		}
+	}
+	visit(pstEntry, -1)
+
+	const inf = int(^uint(0) >> 1)
+	hi := make([]int, n)
+	blist := make([]*bracketList, n)
+	for i := len(order) - 1; i >= 0; i-- {
+		v := order[i]
+
+		// Compute the highest (smallest depth-first number) node reached by a
+		// backedge from v, and from the subtree of each child of v.
+		hi0 := inf
+		for _, b := range backFrom[v] {
+			hi0 = min(hi0, dfsnum[p.other(b.edge, v)])
+		}
+		hi1, hichild := inf, -1
+		for _, c := range children[v] {
+			if hi[c] < hi1 {
+				hi1, hichild = hi[c], c
+			}
+		}
+		hi[v] = min(hi0, hi1)
+		hi2 := inf
+		for _, c := range children[v] {
+			if c != hichild {
+				hi2 = min(hi2, hi[c])
+			}
+		}
+
+		// Compute the bracket list of v.
+		list := &bracketList{}
+		for _, c := range children[v] {
+			list.concat(blist[c])
+		}
+		for _, b := range backTo[v] {
+			list.delete(b)
+			if b.edge >= 0 && p.edges[b.edge].class == -1 {
+				p.edges[b.edge].class = p.newClass()
+			}
+		}
+		for _, b := range backFrom[v] {
+			list.push(b)
+		}
+		if hi2 < hi0 && hi2 < dfsnum[v] {
+			// Create a capping backedge.
+			b := &bracket{edge: -1, recentSize: -1}
+			list.push(b)
+			backTo[order[hi2]] = append(backTo[order[hi2]], b)
+		}
+		blist[v] = list
+
+		// Determine the class of the tree edge from the parent of v to v.
+		if e := parentEdge[v]; e >= 0 {
+			b := list.head
+			if b.recentSize != list.size {
+				b.recentSize = list.size
+				b.recentClass = p.newClass()
+			}
+			p.edges[e].class = b.recentClass
+			if b.recentSize == 1 && b.edge >= 0 {
+				p.edges[b.edge].class = p.edges[e].class
+			}
+		}
+	}
+}
+
+// other returns the endpoint of the edge opposite to v.
+func (p *pst[N]) other(e, v int) int {
+	if p.edges[e].from == v {
+		return p.edges[e].to
+	}
+	return p.edges[e].from
+}
+
+// newClass returns a new cycle equivalence class.
+func (p *pst[N]) newClass() int {
+	p.classes++
+	return p.classes - 1
+}
+
+// canonicalRegions returns the canonical SESE regions of the graph. The edges
+// of each equivalence class are totally ordered by dominance, which coincides
+// with the order in which a directed depth-first search traverses them, and
+// each pair of consecutive edges bounds a canonical region.
+func (p *pst[N]) canonicalRegions() []*RegionNode[N] {
+	byClass := make([][]int, p.classes)
+	visited := make([]bool, len(p.nodes))
+	var visit func(v int)
+	visit = func(v int) {
+		visited[v] = true
+		for _, e := range p.succs[v] {
+			class := p.edges[e].class
+			byClass[class] = append(byClass[class], e)
+			if to := p.edges[e].to; !visited[to] {
+				visit(to)
+			}
+		}
+	}
+	visit(pstEntry)
+
+	var regions []*RegionNode[N]
+	for _, edges := range byClass {
+		for i := 1; i < len(edges); i++ {
+			entry, exit := p.edges[edges[i-1]], p.edges[edges[i]]
+			nodes := p.between(edges[i-1], edges[i])
+			if len(nodes) == 0 {
+				continue
+			}
+			regions = append(regions, &RegionNode[N]{
+				Entry: [2]*graph.Node[N]{p.nodes[entry.from], p.nodes[entry.to]},
+				Exit:  [2]*graph.Node[N]{p.nodes[exit.from], p.nodes[exit.to]},
+				Nodes: nodes,
+			})
+		}
+	}
+	return regions
+}
+
+// between returns the nodes of the graph reachable from the target of the
+// entry edge without traversing the exit edge, in depth-first order.
+func (p *pst[N]) between(entry, exit int) []*graph.Node[N] {
+	reached := make([]bool, len(p.nodes))
+	var visit func(v int)
+	visit = func(v int) {
+		reached[v] = true
+		for _, e := range p.succs[v] {
+			if to := p.edges[e].to; e != exit && !reached[to] {
+				visit(to)
+			}
+		}
+	}
+	visit(p.edges[entry].to)
+
+	var nodes []*graph.Node[N]
+	for i := pstFirst; i < len(p.nodes); i++ {
+		if reached[i] {
+			nodes = append(nodes, p.nodes[i])
+		}
+	}
+	return nodes
+}
+
+// nestRegions nests the given canonical regions by containment and returns
+// the outermost regions. Canonical regions are either nested or disjoint.
+// Sibling regions are ordered by the depth-first number of their first node.
+func nestRegions[N comparable](regions []*RegionNode[N], index func(n *graph.Node[N]) int) []*RegionNode[N] {
+	slices.SortStableFunc(regions, func(a, b *RegionNode[N]) int {
+		return cmp.Compare(len(b.Nodes), len(a.Nodes))
+	})
+	var outermost []*RegionNode[N]
+	for i, region := range regions {
+		var parent *RegionNode[N]
+		for _, cand := range regions[:i] {
+			if !slices.Contains(cand.Nodes, region.Nodes[0]) {
+				continue
+			}
+			if parent == nil || len(cand.Nodes) <= len(parent.Nodes) {
+				parent = cand
+			}
+		}
+		if parent == nil {
+			outermost = append(outermost, region)
+		} else {
+			parent.Children = append(parent.Children, region)
+		}
+	}
+	byFirstNode := func(a, b *RegionNode[N]) int {
+		return cmp.Compare(index(a.Nodes[0]), index(b.Nodes[0]))
+	}
+	for _, region := range regions {
+		slices.SortFunc(region.Children, byFirstNode)
+	}
+	slices.SortFunc(outermost, byFirstNode)
+	return outermost
+}
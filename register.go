@@ -0,0 +1,93 @@
+package decompile
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/nukilabs/decompile/dominator"
+	"github.com/nukilabs/decompile/graph"
+)
+
+// UserPrimitiveKind is the first primitive kind available for user-defined
+// primitives. Kinds below it are reserved for the built-in primitives.
+const UserPrimitiveKind PrimitiveKind = 128
+
+var (
+	// registry guards the registered primitive kinds and structurers.
+	registry sync.RWMutex
+	// kindNames maps user-defined primitive kinds to their names.
+	kindNames = make(map[PrimitiveKind]string)
+	// structurers holds the registered structuring passes, in registration
+	// order.
+	structurers []structurer
+	// nextStructurer is the ID of the next registered structuring pass.
+	nextStructurer int
+)
+
+// structurer is a registered structuring pass.
+type structurer struct {
+	// id identifies the pass for unregistration.
+	id int
+	// fn is a func(*graph.Graph[N], *dominator.Tree[N]) []Primitive[N] for
+	// some N.
+	fn any
+}
+
+// RegisterPrimitiveKind registers the name of a user-defined primitive kind,
+// which is used by PrimitiveKind.String.
+func RegisterPrimitiveKind(kind PrimitiveKind, name string) error {
+	if kind < UserPrimitiveKind {
+		return fmt.Errorf("primitive kind %d is reserved", kind)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if prev, ok := kindNames[kind]; ok {
+		return fmt.Errorf("primitive kind %d already registered as %q", kind, prev)
+	}
+	kindNames[kind] = name
+	return nil
+}
+
+// UnregisterPrimitiveKind removes the name of a user-defined primitive kind
+// registered by RegisterPrimitiveKind, such that the kind may be registered
+// again. Unregistering a kind which is not registered has no effect.
+func UnregisterPrimitiveKind(kind PrimitiveKind) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(kindNames, kind)
+}
+
+// RegisterStructurer registers a custom structuring pass. The primitives
+// produced by the pass are appended by Structure after the built-in passes
+// have run, for graphs with nodes of type N. The returned function
+// unregisters the pass; calling it more than once has no effect.
+func RegisterStructurer[N comparable](fn func(g *graph.Graph[N], dom *dominator.Tree[N]) []Primitive[N]) (unregister func()) {
+	registry.Lock()
+	defer registry.Unlock()
+	id := nextStructurer
+	nextStructurer++
+	structurers = append(structurers, structurer{id: id, fn: fn})
+	return func() {
+		registry.Lock()
+		defer registry.Unlock()
+		structurers = slices.DeleteFunc(slices.Clone(structurers), func(s structurer) bool {
+			return s.id == id
+		})
+	}
+}
+
+// runStructurers runs the registered structuring passes applicable to graphs
+// with nodes of type N, in registration order.
+func runStructurers[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) []Primitive[N] {
+	registry.RLock()
+	fns := structurers
+	registry.RUnlock()
+	prims := make([]Primitive[N], 0)
+	for _, s := range fns {
+		if fn, ok := s.fn.(func(g *graph.Graph[N], dom *dominator.Tree[N]) []Primitive[N]); ok {
+			prims = append(prims, fn(g, dom)...)
+		}
+	}
+	return prims
+}
@@ -1,8 +1,49 @@
 package decompile
 
-import "github.com/nukilabs/decompile/graph"
+import (
+	"context"
+	"errors"
+	"fmt"
 
-func DerivedSequence[N comparable](g *graph.Graph[N]) ([]*graph.Graph[N], [][]*Interval[N]) {
+	"github.com/nukilabs/decompile/graph"
+)
+
+// DerivedSequence computes the derived sequence of graphs of the given control
+// flow graph, the intervals of each graph and the original nodes of each graph,
+// as by DerivedSequenceN with a limit of the number of nodes of the graph. It
+// returns nil if the graph has no root, and the graphs derived so far if the
+// limit is exceeded.
+func DerivedSequence[N comparable](g *graph.Graph[N]) ([]*graph.Graph[N], [][]*Interval[N], []map[graph.ID[N]][]N) {
+	graphs, intervals, values, _ := DerivedSequenceN(g, g.Len())
+	return graphs, intervals, values
+}
+
+// DerivedSequenceN computes the derived sequence of graphs of the given control
+// flow graph and the intervals of each graph, collapsing the graph at most
+// limit times. For each graph, it also maps the ID of each node to the values
+// of the nodes of the given graph it ultimately contains, starting with the
+// header of the interval it was collapsed from (in the order of Nodes); the
+// nodes of the given graph contain their own value. Since each collapse
+// reduces the number of nodes, the sequence of a well-formed graph converges
+// within as many collapses as the graph has nodes. An error is returned if the
+// graph has no root, or if the sequence does not converge within the limit,
+// along with the graphs derived so far.
+func DerivedSequenceN[N comparable](g *graph.Graph[N], limit int) ([]*graph.Graph[N], [][]*Interval[N], []map[graph.ID[N]][]N, error) {
+	return derivedSequence(context.Background(), g, limit)
+}
+
+// derivedSequence computes the derived sequence of graphs as by
+// DerivedSequenceN, until the given context is cancelled.
+func derivedSequence[N comparable](ctx context.Context, g *graph.Graph[N], limit int) ([]*graph.Graph[N], [][]*Interval[N], []map[graph.ID[N]][]N, error) {
+	if g.Root() == nil {
+		return nil, nil, nil, errors.New("unable to derive sequence of graph without root")
+	}
+	values := make([]map[graph.ID[N]][]N, 0)
+	orig := make(map[graph.ID[N]][]N, g.Len())
+	for node := range g.AllNodes() {
+		orig[node.ID()] = []N{node.Value}
+	}
+	values = append(values, orig)
 	graphs := make([]*graph.Graph[N], 0)
 	graphs = append(graphs, g)
 	intervals := make([][]*Interval[N], 0)
@@ -12,14 +53,24 @@ func DerivedSequence[N comparable](g *graph.Graph[N]) ([]*graph.Graph[N], [][]*I
 
 	count := 0
 	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return graphs, intervals, values, err
+		}
+		if i >= limit {
+			return graphs, intervals, values, fmt.Errorf("derived sequence did not converge within %d iterations", limit)
+		}
 		prevGraph := graphs[i]
 		newGraph := graph.New[N]()
 
 		// Make each interval of G^{i-1} a node in G^i.
 		nodes := make([]*graph.Node[N], 0)
+		newValues := make(map[graph.ID[N]][]N, len(intervals[i]))
 		for _, interval := range intervals[i] {
 			node := newGraph.Interval(count)
 			nodes = append(nodes, node)
+			for _, n := range interval.Nodes() {
+				newValues[node.ID()] = append(newValues[node.ID()], values[i][n.ID()]...)
+			}
 			if root.ID() == interval.head.ID() {
 				newGraph.SetRoot(node)
 				root = node
@@ -45,17 +96,20 @@ func DerivedSequence[N comparable](g *graph.Graph[N]) ([]*graph.Graph[N], [][]*I
 		}
 
 		// The collapsed node n of an interval I(h) has the immediate successors
-		// of the exit nodes of I(h) not part of the interval I(h).
+		// of the exit nodes of I(h) not part of the interval I(h). Any node of
+		// the interval may be an exit node, not only its header.
 		for j, interval := range intervals[i] {
 			node := nodes[j]
-			for _, succ := range prevGraph.Successors(interval.head) {
-				if interval.Contains(succ) {
-					continue
-				}
+			for _, exit := range interval.Nodes() {
+				for _, succ := range prevGraph.Successors(exit) {
+					if interval.Contains(succ) {
+						continue
+					}
 
-				for k, succInterval := range intervals[i] {
-					if succInterval.Contains(succ) {
-						newGraph.SetEdge(node, nodes[k])
+					for k, succInterval := range intervals[i] {
+						if succInterval.Contains(succ) {
+							newGraph.SetEdge(node, nodes[k])
+						}
 					}
 				}
 			}
@@ -67,7 +121,8 @@ func DerivedSequence[N comparable](g *graph.Graph[N]) ([]*graph.Graph[N], [][]*I
 
 		graphs = append(graphs, newGraph)
 		intervals = append(intervals, Intervals(newGraph))
+		values = append(values, newValues)
 	}
 
-	return graphs, intervals
+	return graphs, intervals, values, nil
 }
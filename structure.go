@@ -1,6 +1,7 @@
 package decompile
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -10,48 +11,149 @@ import (
 	"github.com/nukilabs/decompile/graph"
 )
 
+// ErrLoopFollow is returned (wrapped) by Structure if the follow node of a loop
+// which is left could not be located, in which case the loop is not
+// structured. It distinguishes a structuring failure from an endless loop,
+// which has no follow since it is never left.
+var ErrLoopFollow = errors.New("unable to locate follow node")
+
 // Structure structures the control flow graph into primitives.
 func Structure[N comparable](g *graph.Graph[N]) ([]Primitive[N], error) {
+	return StructureContext(context.Background(), g)
+}
+
+// StructureContext structures the control flow graph into primitives, like
+// Structure, but stops early if the given context is cancelled, in which case
+// the error of the context is returned.
+func StructureContext[N comparable](ctx context.Context, g *graph.Graph[N]) ([]Primitive[N], error) {
+	return structure(ctx, g, Options[N]{})
+}
+
+// StructureWithOptions structures the control flow graph into primitives, as
+// configured by the given options.
+func StructureWithOptions[N comparable](g *graph.Graph[N], opts Options[N]) ([]Primitive[N], error) {
+	return structure(context.Background(), g, opts)
+}
+
+// structure structures the control flow graph into primitives, as configured
+// by the given options, until the given context is cancelled.
+func structure[N comparable](ctx context.Context, g *graph.Graph[N], opts Options[N]) ([]Primitive[N], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	prims := make([]Primitive[N], 0)
 	errs := make([]error, 0)
 	// Initialize the control flow graph.
 	g.InitOrder()
+	// Compute the derived sequence of graphs, shared by the detection of
+	// irreducible loops and the structuring of loops.
+	graphs, intervals, seqErr := loopSequence(ctx, g)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Report irreducible loops, which are not structured reliably.
+	if nodes := limitEntries(g, graphs, intervals); len(nodes) > 0 {
+		errs = append(errs, fmt.Errorf("%w: entries %v", ErrIrreducible, nodes))
+	}
+	if seqErr != nil {
+		errs = append(errs, seqErr)
+		graphs, intervals = nil, nil
+	}
 	// Compute the dominator tree.
 	dom := dominator.New(g)
 	// Structure loops in the control flow graph.
-	loops, err := StructureLoops(g, dom)
+	loops, err := structureLoops(ctx, g, dom, graphs, intervals, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if err != nil {
 		errs = append(errs, err)
 	}
 	prims = append(prims, loops...)
 	// Structure 2-way conditionals in the control flow graph.
-	conditionals := StructureTwoWayConditionals(g, dom)
+	conditionals := structureTwoWayConditionals(g, dom, loops, opts)
 	prims = append(prims, conditionals...)
+	// Group chains of early-exit conditionals into guard ladders.
+	prims = append(prims, structureGuardLadders(conditionals)...)
+	// Structure n-way conditionals in the control flow graph.
+	switches := StructureNWayConditionals(g, dom)
+	prims = append(prims, switches...)
+	// Run the registered structuring passes.
+	prims = append(prims, runStructurers(g, dom)...)
+	// Record the nesting of primitives.
+	for i, parent := range parents(prims) {
+		if parent != -1 {
+			prims[i].Parent = &prims[parent]
+		}
+	}
 	return prims, errors.Join(errs...)
 }
 
 // StructureLoops structures loops in the given control flow graph.
 func StructureLoops[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) ([]Primitive[N], error) {
-	graphs, intervals := DerivedSequence(g)
+	graphs, intervals, err := loopSequence(context.Background(), g)
+	if err != nil {
+		return make([]Primitive[N], 0), err
+	}
+	return structureLoops(context.Background(), g, dom, graphs, intervals, Options[N]{})
+}
+
+// loopSequence computes the derived sequence of graphs of the given control
+// flow graph and the intervals of each graph, until the given context is
+// cancelled. Since an acyclic graph contains no loops, there is no need to
+// compute the sequence for it, and nil is returned.
+func loopSequence[N comparable](ctx context.Context, g *graph.Graph[N]) ([]*graph.Graph[N], [][]*Interval[N], error) {
+	if !g.HasCycle() {
+		return nil, nil, nil
+	}
+	graphs, intervals, _, err := derivedSequence(ctx, g, g.Len())
+	return graphs, intervals, err
+}
+
+// structureLoops structures loops in the given control flow graph with the
+// given derived sequence of graphs and intervals (as by loopSequence), as
+// configured by the given options, until the given context is cancelled.
+func structureLoops[N comparable](ctx context.Context, g *graph.Graph[N], dom *dominator.Tree[N], graphs []*graph.Graph[N], intervals [][]*Interval[N], opts Options[N]) ([]Primitive[N], error) {
 	prims := make([]Primitive[N], 0)
 	errs := make([]error, 0)
 	for i := range graphs {
+		if err := ctx.Err(); err != nil {
+			return prims, err
+		}
 		for _, interval := range intervals[i] {
-			head, latch, ok := findLatch(graphs[0], interval, intervals)
+			if err := ctx.Err(); err != nil {
+				return prims, err
+			}
+			head, latch, ok, err := findLatch(graphs[0], interval, intervals)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
 			if ok && !latch.IsLoopNode {
 				latch.IsLoopLatch = true
 				nodes := markNodesInLoop(g, head, latch, dom)
-				kind, err := findLoopKind(g, head, latch, nodes)
+				kind, err := findLoopKind(g, head, latch, nodes, opts)
 				if err != nil {
 					errs = append(errs, err)
+					if opts.EmitUnstructured {
+						prims = append(prims, unstructuredLoop(head, latch, nodes))
+					}
 					continue
 				}
-				follow, err := findLoopFollow(g, kind, head, latch, nodes, dom)
+				follow, err := findLoopFollow(g, kind, head, latch, nodes, dom, opts)
 				if err != nil {
 					errs = append(errs, err)
+					if opts.EmitUnstructured {
+						prims = append(prims, unstructuredLoop(head, latch, nodes))
+					}
 					continue
 				}
 
+				// An endless loop with an exit is left by a break.
+				if kind == EndlessLoop && follow != nil {
+					kind = ConditionalEndlessLoop
+				}
+
 				// Create loop primitive.
 				prim := Primitive[N]{
 					Kind:  kind,
@@ -65,6 +167,21 @@ func StructureLoops[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) ([]
 					prim.Extra["follow"] = follow.Value
 					prim.Exit = follow.Value
 				}
+				// Record the condition of a conditional self-loop.
+				if kind == SelfLoop && follow != nil {
+					prim.Extra["cond"] = head.Value
+				}
+
+				// Record the sources of all back edges to the loop header, of which
+				// only one is the structural latch, such that a continue can be
+				// emitted at each.
+				latches := 0
+				for _, pred := range ascReversePostOrder(g.Predecessors(head)) {
+					if dom.Dominates(head, pred) {
+						prim.Extra[fmt.Sprintf("latch%d", latches)] = pred.Value
+						latches++
+					}
+				}
 
 				// Remove the follow node from the loop body.
 				for i, node := range nodes {
@@ -78,6 +195,16 @@ func StructureLoops[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) ([]
 					prim.Body = append(prim.Body, node.Value)
 				}
 
+				// Record the exits of the loop other than to the follow node.
+				for _, node := range ascReversePostOrder(slices.Clone(nodes)) {
+					for _, succ := range g.Successors(node) {
+						if !contains(nodes, succ) && (follow == nil || succ.ID() != follow.ID()) {
+							prim.AbnormalExits = append(prim.AbnormalExits, node.Value)
+							break
+						}
+					}
+				}
+
 				prims = append(prims, prim)
 			}
 		}
@@ -85,9 +212,34 @@ func StructureLoops[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) ([]
 	return prims, errors.Join(errs...)
 }
 
+// unstructuredLoop returns a primitive of kind None capturing the nodes of a
+// loop which cannot be structured.
+func unstructuredLoop[N comparable](head, latch *graph.Node[N], nodes []*graph.Node[N]) Primitive[N] {
+	prim := Primitive[N]{
+		Kind:  None,
+		Entry: head.Value,
+		Extra: map[string]N{
+			"latch": latch.Value,
+		},
+	}
+	for _, node := range nodes {
+		prim.Body = append(prim.Body, node.Value)
+	}
+	return prim
+}
+
 // findLatch locates the loop latch node in the interval, based on the interval
-// header node. The boolean return value indicates success.
-func findLatch[N comparable](g *graph.Graph[N], interval *Interval[N], intervals [][]*Interval[N]) (*graph.Node[N], *graph.Node[N], bool) {
+// header node. The boolean return value indicates whether the interval has a
+// latch, and an error is returned if the latch node in the derived sequence of
+// graphs has no counterpart in the original control flow graph.
+//
+// A loop header may have several back edges (e.g. from continue statements),
+// of which the latch is the one from the predecessor with the highest order.
+// The loop is structured once, and the sources of all back edges are recorded
+// as "latch0", "latch1", etc. extras of the loop. The branches of the
+// secondary latches are structured as conditionals continuing with the loop
+// header (see StructureTwoWayConditionals).
+func findLatch[N comparable](g *graph.Graph[N], interval *Interval[N], intervals [][]*Interval[N]) (*graph.Node[N], *graph.Node[N], bool, error) {
 	var latch *graph.Node[N]
 	// iis is used to look up the nodes belonging to an interval, e.g. I_1. Note,
 	var iis []*Interval[N]
@@ -103,9 +255,10 @@ func findLatch[N comparable](g *graph.Graph[N], interval *Interval[N], intervals
 	}
 	if latch != nil {
 		// Locate node in original control flow graph corresponding to the latch
-		// node in the derived sequence of graphs.
-		if l, ok := g.GetNode(latch.Value); ok {
-			return interval.head, l, true
+		// node in the derived sequence of graphs. Nodes other than interval nodes
+		// (e.g. split nodes) are in the original control flow graph.
+		if latch.Kind != graph.IntervalNode {
+			return interval.head, latch, true, nil
 		}
 		h := findOrigHead(interval.head, iis)
 		cands := descReversePostOrder(g.Predecessors(h))
@@ -115,10 +268,13 @@ func findLatch[N comparable](g *graph.Graph[N], interval *Interval[N], intervals
 				break
 			}
 		}
-		l := findOrigLatch(latch, cands, iis)
-		return h, l, true
+		l, err := findOrigLatch(latch, cands, iis)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return h, l, true, nil
 	}
-	return nil, nil, false
+	return nil, nil, false, nil
 }
 
 // findOrigHead returns the loop header node in the original control flow graph
@@ -136,16 +292,16 @@ func findOrigHead[N comparable](head *graph.Node[N], intervals []*Interval[N]) *
 // findOrigLatch returns the latch node in the original control flow graph
 // corresponding to the latch node of an interval in the derived sequence of
 // graphs.
-func findOrigLatch[N comparable](latch *graph.Node[N], cands []*graph.Node[N], intervals []*Interval[N]) *graph.Node[N] {
+func findOrigLatch[N comparable](latch *graph.Node[N], cands []*graph.Node[N], intervals []*Interval[N]) (*graph.Node[N], error) {
 	i, ok := getInterval(latch.ID(), intervals)
 	if !ok {
-		return latch
+		return latch, nil
 	}
 	l, ok := findNodeInInterval(cands, i, intervals)
 	if !ok {
-		panic("unable to find latch node in original control flow graph")
+		return nil, fmt.Errorf("unable to find latch node of %v in original control flow graph", latch)
 	}
-	return l
+	return l, nil
 }
 
 // findNodeInInterval locates the a latch node in the original control flow
@@ -176,43 +332,53 @@ func getInterval[N comparable](id graph.ID[N], intervals []*Interval[N]) (*Inter
 	return intervals[id.Idx], true
 }
 
-// loop returns the nodes of the loop (latch, I.head), marking the loop header
+// markNodesInLoop returns the nodes of the loop (latch, head), marking the loop
+// header and the nodes of the loop. The loop is the natural loop of the back
+// edges to the header, i.e. the header and the nodes dominated by it which
+// reach the latch, or another predecessor of the header dominated by it,
+// without passing through the header. The header comes first, followed by the
+// other nodes in reverse postorder.
 func markNodesInLoop[N comparable](g *graph.Graph[N], head, latch *graph.Node[N], dom *dominator.Tree[N]) []*graph.Node[N] {
-	nodes := []*graph.Node[N]{head}
 	head.IsLoopNode = true
 	head.IsLoopHead = true
-	for _, node := range ascReversePostOrder(g.Nodes()) {
-		// The loop is formed of all nodes that are between x and y in terms of
-		// node numbering.
-		if head.Order < node.Order && node.Order <= latch.Order {
-			// The nodes belong to the same interval, since the interval header
-			// (i.e. x) dominates all nodes of the interval, and in a loop, the
-			// loop header node dominates all nodes of the loop. If a node belongs
-			// to a different interval, it is not dominated by the loop header
-			// node, thus it cannot belong to the same loop.
-			if dom.Dominates(head, node) {
-				nodes = append(nodes, node)
-				node.IsLoopNode = true
-			}
+	visited := map[graph.ID[N]]bool{head.ID(): true}
+	work := newStack[N]()
+	push := func(n *graph.Node[N]) {
+		if !visited[n.ID()] {
+			visited[n.ID()] = true
+			work.push(n)
 		}
-		if node.Order > latch.Order {
-			break
+	}
+	push(latch)
+	for _, pred := range g.Predecessors(head) {
+		if dom.Dominates(head, pred) {
+			push(pred)
 		}
 	}
-	return nodes
+	body := make([]*graph.Node[N], 0)
+	for !work.empty() {
+		n := work.pop()
+		n.IsLoopNode = true
+		body = append(body, n)
+		for _, pred := range g.Predecessors(n) {
+			if dom.Dominates(head, pred) {
+				push(pred)
+			}
+		}
+	}
+	return append([]*graph.Node[N]{head}, ascReversePostOrder(body)...)
 }
 
 // findLoopKind determines the structural type of a loop based on the control flow properties
 // of its header and latch nodes, returning one of PreTestedLoop, PostTestedLoop, or EndlessLoop.
-func findLoopKind[N comparable](g *graph.Graph[N], head, latch *graph.Node[N], nodes []*graph.Node[N]) (PrimitiveKind, error) {
+func findLoopKind[N comparable](g *graph.Graph[N], head, latch *graph.Node[N], nodes []*graph.Node[N], opts Options[N]) (PrimitiveKind, error) {
 	// Special case: self-loop where the header is also the latch
-	// This forms a post-tested loop structure (do-while loop)
-	if head.ID() == latch.ID() {
-		return PostTestedLoop, nil
+	if g.HasEdge(head, head) && head.ID() == latch.ID() {
+		return SelfLoop, nil
 	}
 
-	headSuccs := g.Successors(head)
-	latchSuccs := g.Successors(latch)
+	headSuccs := opts.successors(g, head)
+	latchSuccs := opts.successors(g, latch)
 
 	switch len(latchSuccs) {
 	// Case: Latch node has 2 outgoing edges (conditional latch)
@@ -232,8 +398,11 @@ func findLoopKind[N comparable](g *graph.Graph[N], head, latch *graph.Node[N], n
 		case 1:
 			// With unconditional header but conditional latch, this is a post-tested loop
 			return PostTestedLoop, nil
+		// Case: Header node has more than 2 outgoing edges (n-way header)
 		default:
-			return None, fmt.Errorf("unsupported %d-way header node", len(headSuccs))
+			// With n-way header and conditional latch, the loop condition is
+			// evaluated at the end, around a switch at the beginning of the body
+			return PostTestedLoop, nil
 		}
 	// Case: Latch node has 1 outgoing edge (unconditional latch)
 	case 1:
@@ -246,18 +415,25 @@ func findLoopKind[N comparable](g *graph.Graph[N], head, latch *graph.Node[N], n
 		case 1:
 			// With both unconditional header and latch, this forms an endless loop
 			return EndlessLoop, nil
+		// Case: Header node has more than 2 outgoing edges (n-way header)
 		default:
-			return None, fmt.Errorf("unsupported %d-way header node", len(headSuccs))
+			// With n-way header and unconditional latch, this forms an endless loop
+			// around a dispatch (e.g. an interpreter loop), where the cases branch
+			// back to the header
+			return EndlessLoop, nil
 		}
 	default:
 		return None, fmt.Errorf("unsupported %d-way latching node", len(latchSuccs))
 	}
 }
 
-// findLoopFollow returns the follow node of the loop (latch, head).
-func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, latch *graph.Node[N], nodes []*graph.Node[N], dom *dominator.Tree[N]) (*graph.Node[N], error) {
-	headSuccs := g.Successors(head)
-	latchSuccs := g.Successors(latch)
+// findLoopFollow returns the follow node of the loop (latch, head). A nil
+// follow without error means that the loop has no exit, i.e. that it is
+// endless. An error wrapping ErrLoopFollow is returned if the loop has an exit
+// but its follow could not be located.
+func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, latch *graph.Node[N], nodes []*graph.Node[N], dom *dominator.Tree[N], opts Options[N]) (*graph.Node[N], error) {
+	headSuccs := opts.successors(g, head)
+	latchSuccs := opts.successors(g, latch)
 
 	switch kind {
 	case PreTestedLoop:
@@ -269,6 +445,9 @@ func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, l
 		// that leads to the loop body.
 		for targetNode.ID() != headSuccs[0].ID() && targetNode.ID() != headSuccs[1].ID() {
 			targetNode = dom.DominatorOf(targetNode)
+			if targetNode == nil {
+				return nil, fmt.Errorf("%w of pre-tested loop: latch not dominated by a successor of the header", ErrLoopFollow)
+			}
 		}
 
 		switch {
@@ -288,7 +467,7 @@ func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, l
 		default:
 			// If we can't determine the follow node with the above rules,
 			// the loop structure might be abnormal or complex
-			return nil, errors.New("unable to locate follow node of pre-tested loop")
+			return nil, fmt.Errorf("%w of pre-tested loop", ErrLoopFollow)
 		}
 
 	case PostTestedLoop:
@@ -304,44 +483,69 @@ func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, l
 			return latchSuccs[0], nil
 
 		default:
-			return nil, errors.New("unable to locate follow node of post-tested loop")
+			return nil, fmt.Errorf("%w of post-tested loop", ErrLoopFollow)
 		}
 
+	case SelfLoop:
+		// The follow of a conditional self-loop is its successor other than
+		// itself; an unconditional self-loop has none.
+		for _, succ := range headSuccs {
+			if succ.ID() != head.ID() {
+				return succ, nil
+			}
+		}
+		return nil, nil
+
 	case EndlessLoop:
 		// For endless loops, we need to find an exit point by examining conditional branches
 		// Initial value is maximum integer to ensure any valid node has lower order
-		followRevPostNum := math.MaxInt64
+		followRevPostNum := math.MaxInt
+		followWeight := math.Inf(-1)
 		var follow *graph.Node[N]
 
-		// Examine all 2-way conditional nodes within the loop to find potential exit points
+		// better reports whether the exit edge from n to succ is preferred over
+		// the current follow candidate. Edges with a higher weight are preferred,
+		// and edges without weight are considered to have a weight of zero; ties
+		// are broken by the lower reverse post order number.
+		better := func(n, succ *graph.Node[N]) bool {
+			w, _ := g.EdgeWeight(n, succ)
+			return w > followWeight || (w == followWeight && succ.Order < followRevPostNum)
+		}
+
+		// Examine all conditional nodes within the loop to find potential exit points
 		for _, n := range nodes {
-			nSuccs := g.Successors(n)
-			if len(nSuccs) != 2 {
-				// Skip nodes that aren't 2-way conditionals
+			nSuccs := opts.successors(g, n)
+			if len(nSuccs) < 2 {
+				// Skip nodes that aren't conditionals
 				continue
 			}
 
-			switch {
-			// If first successor is outside the loop and has lower reverse post order number
-			// than our current candidate, it becomes the new follow node candidate
-			case !contains(nodes, nSuccs[0]) && nSuccs[0].Order < followRevPostNum:
-				followRevPostNum = nSuccs[0].Order
-				follow = nSuccs[0]
-
-			// If second successor is outside the loop and has lower reverse post order number
-			// than our current candidate, it becomes the new follow node candidate
-			case !contains(nodes, nSuccs[1]) && nSuccs[1].Order < followRevPostNum:
-				followRevPostNum = nSuccs[1].Order
-				follow = nSuccs[1]
+			// The first successor which is outside the loop and is preferred over our
+			// current candidate becomes the new follow node candidate
+			for _, succ := range nSuccs {
+				if !contains(nodes, succ) && better(n, succ) {
+					followRevPostNum = succ.Order
+					followWeight, _ = g.EdgeWeight(n, succ)
+					follow = succ
+					break
+				}
 			}
 		}
 
 		// If we found a valid follow node (exit point)
-		if followRevPostNum != math.MaxInt64 {
+		if follow != nil {
 			return follow, nil
 		}
 
-		// No exit point found - this is a truly endless loop
+		// No conditional exit point found. The loop is truly endless unless it
+		// is left unconditionally (e.g. by a goto out of the loop).
+		for _, n := range nodes {
+			for _, succ := range g.Successors(n) {
+				if !contains(nodes, succ) {
+					return nil, fmt.Errorf("%w of endless loop: unconditional exit from %v", ErrLoopFollow, n)
+				}
+			}
+		}
 		return nil, nil
 	default:
 		return nil, errors.New("unsupported loop kind")
@@ -349,19 +553,65 @@ func findLoopFollow[N comparable](g *graph.Graph[N], kind PrimitiveKind, head, l
 }
 
 // StructureTwoWayConditionals structures 2-way conditionals in the given control
-// flow graph.
+// flow graph. If one arm of a conditional is a sink (e.g. an early return), the
+// other arm is its follow and the sink is recorded as "sink" extra. Likewise,
+// if one arm is a back edge to the header of an enclosing loop (i.e. a
+// continue), the other arm is its follow and the header is recorded as
+// "continue" extra. If the arms do not merge at a node dominated by the
+// conditional, its follow is its immediate post-dominator, if any; if that is
+// the header of an enclosing loop, i.e. both arms continue with the next
+// iteration, the header is also recorded as "loop" extra. If the follow itself
+// is a sink (e.g. a return block shared by both arms), it is recorded as
+// "follow_is_exit" extra. Conditionals for which no follow is found, and which
+// are not nested within another conditional, are returned as
+// UnresolvedConditional primitives whose body holds the nodes dominated by the
+// conditional node.
 func StructureTwoWayConditionals[N comparable](g *graph.Graph[N], dom *dominator.Tree[N]) []Primitive[N] {
+	return structureTwoWayConditionals(g, dom, nil, Options[N]{})
+}
+
+// structureTwoWayConditionals structures 2-way conditionals in the given
+// control flow graph, as configured by the given options.
+//
+// Loop headers and latches are skipped, except for the 2-way latches of the
+// given loops which do not control their loop (see latchConditional).
+func structureTwoWayConditionals[N comparable](g *graph.Graph[N], dom *dominator.Tree[N], loops []Primitive[N], opts Options[N]) []Primitive[N] {
 	prims := make([]Primitive[N], 0)
 	unresolved := newStack[N]()
+	// Post-dominator tree, computed on demand.
+	var pdom *dominator.Tree[N]
 	for _, node := range descReversePostOrder(g.Nodes()) {
-		if len(g.Successors(node)) == 2 && !node.IsLoopHead && !node.IsLoopLatch {
-			var follow *graph.Node[N]
-			for _, n := range dom.DominatedBy(node) {
-				if len(g.Predecessors(n)) < 2 {
-					continue
+		if g.OutDegree(node) == 2 && node.IsLoopLatch && !node.IsLoopHead {
+			if prim, ok := latchConditional(g, node, loops); ok {
+				prims = append(prims, prim)
+			}
+			continue
+		}
+		if g.OutDegree(node) == 2 && !node.IsLoopHead && !node.IsLoopLatch {
+			// An arm without successors (e.g. an early return) never merges
+			// back, so the other arm is the follow of a single-armed conditional.
+			follow, sink := sinkArmFollow(g, node, opts)
+			var cont, loop *graph.Node[N]
+			if follow == nil {
+				follow, cont = continueArmFollow(g, dom, node, opts)
+			}
+			if follow == nil {
+				follow = conditionalFollow(g, dom, node, opts)
+			}
+			if follow == nil {
+				// The arms do not merge at a node dominated by the conditional
+				// node (e.g. the merge node is also entered from elsewhere), so
+				// fall back to its immediate post-dominator.
+				if pdom == nil {
+					pdom = dominator.NewPostDominator(g)
 				}
-				if follow == nil || follow.Order < n.Order {
-					follow = n
+				if ipdom := pdom.DominatorOf(node); ipdom != nil && ipdom.Kind == graph.DefaultNode {
+					switch {
+					case !ipdom.IsLoopHead:
+						follow = ipdom
+					case dom.Dominates(ipdom, node):
+						follow, loop = ipdom, ipdom
+					}
 				}
 			}
 			if follow != nil {
@@ -374,6 +624,20 @@ func StructureTwoWayConditionals[N comparable](g *graph.Graph[N], dom *dominator
 						"follow": follow.Value,
 					},
 				}
+				if sink != nil {
+					prim.Extra["sink"] = sink.Value
+				}
+				if cont != nil {
+					prim.Extra["continue"] = cont.Value
+				}
+				if loop != nil {
+					prim.Extra["loop"] = loop.Value
+				}
+				// A follow without successors (e.g. a shared return block) is the
+				// exit of the function rather than a merge with code after it.
+				if g.OutDegree(follow) == 0 {
+					prim.Extra["follow_is_exit"] = follow.Value
+				}
 				for i := 0; !unresolved.empty(); i++ {
 					n := unresolved.pop()
 					prim.Body = append(prim.Body, n.Value)
@@ -384,5 +648,210 @@ func StructureTwoWayConditionals[N comparable](g *graph.Graph[N], dom *dominator
 			}
 		}
 	}
+	// Capture the regions of conditionals for which no follow node was found,
+	// rather than dropping them. With EmitUnstructured, they are emitted as
+	// primitives of kind None like other unstructured regions.
+	for !unresolved.empty() {
+		node := unresolved.pop()
+		kind := UnresolvedConditional
+		if opts.EmitUnstructured {
+			kind = None
+		}
+		prim := Primitive[N]{
+			Kind:  kind,
+			Entry: node.Value,
+			Extra: map[string]N{
+				"cond": node.Value,
+			},
+		}
+		for _, n := range ascReversePostOrder(dominatedSubtree(dom, node)) {
+			prim.Body = append(prim.Body, n.Value)
+		}
+		prims = append(prims, prim)
+	}
 	return prims
 }
+
+// latchConditional returns the conditional of the given 2-way latch node within
+// the body of its loop, provided that the latch does not control the loop. The
+// boolean return value indicates success.
+//
+// The branch of the latch of a post-tested loop (or self-loop) is the loop
+// condition, from which findLoopFollow determines the follow of the loop, and
+// no conditional is structured. The loop condition of a pre-tested loop is
+// evaluated at the header instead, and findLoopFollow determines its follow
+// from the header, such that the branch of the latch is an ordinary
+// conditional at the end of the loop body: one arm is the back edge, i.e. the
+// follow of the conditional is the loop header (the next iteration), and the
+// other arm leaves the loop (e.g. if (c) break;), recorded as "break" extra.
+// The loop header is also recorded as "loop" extra.
+func latchConditional[N comparable](g *graph.Graph[N], latch *graph.Node[N], loops []Primitive[N]) (Primitive[N], bool) {
+	for _, loop := range loops {
+		if value, ok := loop.Extra["latch"]; !ok || value != latch.Value {
+			continue
+		}
+		if loop.Kind == PostTestedLoop || loop.Kind == SelfLoop || loop.Kind == None {
+			return Primitive[N]{}, false
+		}
+		head, ok := g.GetNode(loop.Entry)
+		if !ok || !g.HasEdge(latch, head) {
+			return Primitive[N]{}, false
+		}
+		prim := Primitive[N]{
+			Kind:  TwoWayConditional,
+			Entry: latch.Value,
+			Exit:  head.Value,
+			Extra: map[string]N{
+				"cond":   latch.Value,
+				"follow": head.Value,
+				"loop":   head.Value,
+			},
+		}
+		for _, succ := range g.Successors(latch) {
+			if succ.ID() != head.ID() {
+				prim.Extra["break"] = succ.Value
+			}
+		}
+		return prim, true
+	}
+	return Primitive[N]{}, false
+}
+
+// conditionalFollow returns the follow node of the given 2-way node among the
+// nodes immediately dominated by it with at least two predecessors, as
+// selected by the follow strategy of the given options, or nil if there are
+// no candidates.
+func conditionalFollow[N comparable](g *graph.Graph[N], dom *dominator.Tree[N], node *graph.Node[N], opts Options[N]) *graph.Node[N] {
+	var dist map[graph.ID[N]]int
+	if opts.FollowStrategy == FollowBFS {
+		dist = g.BFSDistances(node)
+	}
+	var follow *graph.Node[N]
+	for _, n := range dom.DominatedBy(node) {
+		if g.InDegree(n) < 2 {
+			continue
+		}
+		switch {
+		case follow == nil:
+			follow = n
+		case opts.FollowStrategy == FollowBFS:
+			if dist[n.ID()] < dist[follow.ID()] || (dist[n.ID()] == dist[follow.ID()] && n.Order < follow.Order) {
+				follow = n
+			}
+		default:
+			if follow.Order < n.Order {
+				follow = n
+			}
+		}
+	}
+	return follow
+}
+
+// sinkArmFollow returns the follow node of the given 2-way node if exactly one
+// of its arms is a sink (i.e. has no successors), or a straight-line path of
+// nodes not entered from elsewhere which ends in a sink (e.g. cleanup code
+// followed by a return), in which case the follow is the other arm. Sinks take
+// precedence over paths to sinks. The sink arm is returned as second value.
+func sinkArmFollow[N comparable](g *graph.Graph[N], node *graph.Node[N], opts Options[N]) (follow, sink *graph.Node[N]) {
+	succs := opts.successors(g, node)
+	if len(succs) != 2 {
+		return nil, nil
+	}
+	isSink := func(n *graph.Node[N]) bool {
+		return n.ID() != node.ID() && g.OutDegree(n) == 0
+	}
+	isSinkPath := func(n *graph.Node[N]) bool {
+		for i := 0; n.ID() != node.ID() && i < g.Len(); i++ {
+			if g.OutDegree(n) == 0 {
+				return true
+			}
+			if g.OutDegree(n) != 1 || g.InDegree(n) != 1 {
+				return false
+			}
+			n = g.Successors(n)[0]
+			if g.InDegree(n) != 1 {
+				return false
+			}
+		}
+		return false
+	}
+	switch {
+	case isSink(succs[0]) && !isSink(succs[1]):
+		return succs[1], succs[0]
+	case isSink(succs[1]) && !isSink(succs[0]):
+		return succs[0], succs[1]
+	case isSink(succs[0]) || isSink(succs[1]):
+		return nil, nil
+	case isSinkPath(succs[0]) && !isSinkPath(succs[1]):
+		return succs[1], succs[0]
+	case isSinkPath(succs[1]) && !isSinkPath(succs[0]):
+		return succs[0], succs[1]
+	}
+	return nil, nil
+}
+
+// continueArmFollow returns the follow node of the given 2-way node if exactly
+// one of its arms is a back edge to the header of a loop enclosing the node,
+// i.e. a continue, in which case the follow is the other arm. The loop header
+// is returned as second value.
+func continueArmFollow[N comparable](g *graph.Graph[N], dom *dominator.Tree[N], node *graph.Node[N], opts Options[N]) (follow, head *graph.Node[N]) {
+	succs := opts.successors(g, node)
+	if len(succs) != 2 {
+		return nil, nil
+	}
+	isContinue := func(n *graph.Node[N]) bool {
+		return n.IsLoopHead && dom.Dominates(n, node)
+	}
+	switch {
+	case isContinue(succs[0]) && !isContinue(succs[1]):
+		return succs[1], succs[0]
+	case isContinue(succs[1]) && !isContinue(succs[0]):
+		return succs[0], succs[1]
+	}
+	return nil, nil
+}
+
+// dominatedSubtree returns the nodes strictly dominated by the given node.
+func dominatedSubtree[N comparable](dom *dominator.Tree[N], node *graph.Node[N]) []*graph.Node[N] {
+	nodes := make([]*graph.Node[N], 0)
+	work := newStack[N]()
+	work.push(node)
+	for !work.empty() {
+		for _, n := range dom.DominatedBy(work.pop()) {
+			nodes = append(nodes, n)
+			work.push(n)
+		}
+	}
+	return nodes
+}
+
+// AbnormalEntries returns the edges which enter the region of a primitive
+// without passing through its entry node. The targets of such edges are not
+// single-entry and require a goto and label in the generated code.
+func AbnormalEntries[N comparable](g *graph.Graph[N], prims []Primitive[N]) [][2]N {
+	edges := make([][2]N, 0)
+	seen := make(map[[2]N]struct{})
+	for _, prim := range prims {
+		nodes := region(g, prim)
+		targets := make([]*graph.Node[N], 0, len(nodes))
+		for value := range nodes {
+			if node, ok := g.GetNode(value); ok && value != prim.Entry {
+				targets = append(targets, node)
+			}
+		}
+		for _, target := range ascReversePostOrder(targets) {
+			for _, pred := range ascReversePostOrder(g.Predecessors(target)) {
+				if _, ok := nodes[pred.Value]; ok {
+					continue
+				}
+				edge := [2]N{pred.Value, target.Value}
+				if _, ok := seen[edge]; ok {
+					continue
+				}
+				seen[edge] = struct{}{}
+				edges = append(edges, edge)
+			}
+		}
+	}
+	return edges
+}
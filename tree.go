@@ -0,0 +1,112 @@
+package decompile
+
+// PrimitiveTree is a tree of nested primitives, each node of which holds a
+// primitive and the primitives nested directly within it. The root of the
+// tree holds no primitive and has the outermost primitives as children.
+type PrimitiveTree[N comparable] struct {
+	// Primitive of the node; the zero value for the root node.
+	Primitive Primitive[N]
+	// Children holds the primitives nested directly within the primitive.
+	Children []*PrimitiveTree[N]
+}
+
+// BuildPrimitiveTree nests the given primitives by containment. A primitive
+// whose entry is in the body of another primitive becomes a child of the
+// smallest such primitive. Children keep the order of the given primitives.
+func BuildPrimitiveTree[N comparable](prims []Primitive[N]) *PrimitiveTree[N] {
+	root := &PrimitiveTree[N]{}
+	nodes := make([]*PrimitiveTree[N], len(prims))
+	for i, prim := range prims {
+		nodes[i] = &PrimitiveTree[N]{Primitive: prim}
+	}
+	for i, parent := range parents(prims) {
+		if parent == -1 {
+			root.Children = append(root.Children, nodes[i])
+		} else {
+			nodes[parent].Children = append(nodes[parent].Children, nodes[i])
+		}
+	}
+	return root
+}
+
+// parents returns the index of the immediately enclosing primitive of each of
+// the given primitives, i.e. the smallest primitive whose body contains the
+// entry of the primitive, or -1 if there is none.
+func parents[N comparable](prims []Primitive[N]) []int {
+	bodies := make([]map[N]struct{}, len(prims))
+	for i, prim := range prims {
+		bodies[i] = bodySet(prim)
+	}
+	idxs := make([]int, len(prims))
+	for i, prim := range prims {
+		parent := -1
+		for j := range prims {
+			if i == j || !encloses(bodies, i, j) {
+				continue
+			}
+			if _, ok := bodies[j][prim.Entry]; !ok {
+				continue
+			}
+			if parent == -1 || encloses(bodies, j, parent) {
+				parent = j
+			}
+		}
+		idxs[i] = parent
+	}
+	return idxs
+}
+
+// encloses reports whether the primitive at index j may enclose the primitive
+// at index i, based on the size of their bodies. Primitives with bodies of
+// equal size are ordered by index, such that the containment is acyclic.
+func encloses[N comparable](bodies []map[N]struct{}, i, j int) bool {
+	if len(bodies[i]) != len(bodies[j]) {
+		return len(bodies[i]) < len(bodies[j])
+	}
+	return j < i
+}
+
+// PrimitiveVisitor visits the primitives of a primitive tree by category. The
+// kind of the visited primitive distinguishes the primitives of a category.
+type PrimitiveVisitor[N comparable] interface {
+	// VisitLoop visits a pre-tested, post-tested, endless or self-loop.
+	VisitLoop(prim Primitive[N]) error
+	// VisitConditional visits a 2-way, short-circuit or unresolved
+	// conditional.
+	VisitConditional(prim Primitive[N]) error
+	// VisitNWay visits an n-way conditional.
+	VisitNWay(prim Primitive[N]) error
+	// VisitOther visits any other primitive, such as guard ladders,
+	// unstructured regions and user-defined primitives.
+	VisitOther(prim Primitive[N]) error
+}
+
+// Walk traverses the given primitive tree depth-first, visiting each primitive
+// before the primitives nested within it, in the order of the children. The
+// root of the tree, which holds no primitive, is not visited. The walk is
+// aborted at the first error returned by the visitor, which is returned.
+func Walk[N comparable](tree *PrimitiveTree[N], v PrimitiveVisitor[N]) error {
+	for _, child := range tree.Children {
+		if err := visit(child.Primitive, v); err != nil {
+			return err
+		}
+		if err := Walk(child, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visit calls the method of the visitor for the category of the primitive.
+func visit[N comparable](prim Primitive[N], v PrimitiveVisitor[N]) error {
+	switch prim.Kind {
+	case PreTestedLoop, PostTestedLoop, EndlessLoop, ConditionalEndlessLoop, SelfLoop:
+		return v.VisitLoop(prim)
+	case TwoWayConditional, ShortCircuitConditional, UnresolvedConditional:
+		return v.VisitConditional(prim)
+	case NWayConditional:
+		return v.VisitNWay(prim)
+	default:
+		return v.VisitOther(prim)
+	}
+}
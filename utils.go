@@ -1,25 +1,32 @@
 package decompile
 
 import (
+	"cmp"
 	"slices"
 
 	"github.com/nukilabs/decompile/graph"
 )
 
-// descReversePostOrder returns a slice of nodes in descending reverse postorder.
+// descReversePostOrder returns a copy of the given nodes sorted by descending
+// reverse postorder number (as initialized by InitOrder), i.e. with the nodes
+// closest to the exits first. The given slice is not modified.
 func descReversePostOrder[N comparable](nodes []*graph.Node[N]) []*graph.Node[N] {
-	slices.SortFunc(nodes, func(a, b *graph.Node[N]) int {
-		return b.Order - a.Order
+	sorted := slices.Clone(nodes)
+	slices.SortFunc(sorted, func(a, b *graph.Node[N]) int {
+		return cmp.Compare(b.Order, a.Order)
 	})
-	return nodes
+	return sorted
 }
 
-// ascReversePostOrder returns a slice of nodes in ascending reverse postorder.
+// ascReversePostOrder returns a copy of the given nodes sorted by ascending
+// reverse postorder number (as initialized by InitOrder), i.e. with the root
+// first. The given slice is not modified.
 func ascReversePostOrder[N comparable](nodes []*graph.Node[N]) []*graph.Node[N] {
-	slices.SortFunc(nodes, func(a, b *graph.Node[N]) int {
-		return a.Order - b.Order
+	sorted := slices.Clone(nodes)
+	slices.SortFunc(sorted, func(a, b *graph.Node[N]) int {
+		return cmp.Compare(a.Order, b.Order)
 	})
-	return nodes
+	return sorted
 }
 
 // contains returns true if the given node is in the list of nodes.
@@ -28,3 +35,48 @@ func contains[N comparable](nodes []*graph.Node[N], node *graph.Node[N]) bool {
 		return n.ID() == node.ID()
 	})
 }
+
+// bodySet returns the set of node values in the body of the given primitive,
+// including its entry node.
+func bodySet[N comparable](prim Primitive[N]) map[N]struct{} {
+	set := make(map[N]struct{}, len(prim.Body)+1)
+	set[prim.Entry] = struct{}{}
+	for _, v := range prim.Body {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+// region returns the set of nodes structured by the given primitive.
+//
+// The region of a loop is its body. The region of a conditional is every node
+// reachable from its entry along forward edges (in terms of node ordering)
+// without passing through its follow node, or the arm leaving the loop of a
+// conditional latch ("break" extra).
+func region[N comparable](g *graph.Graph[N], prim Primitive[N]) map[N]struct{} {
+	set := bodySet(prim)
+	follow, ok := prim.Extra["follow"]
+	if (prim.Kind != TwoWayConditional && prim.Kind != NWayConditional) || !ok {
+		return set
+	}
+	entry, ok := g.GetNode(prim.Entry)
+	if !ok {
+		return set
+	}
+	brk, hasBreak := prim.Extra["break"]
+	visited := map[graph.ID[N]]bool{entry.ID(): true}
+	work := newStack[N]()
+	work.push(entry)
+	for !work.empty() {
+		n := work.pop()
+		for _, succ := range g.Successors(n) {
+			if succ.Order <= n.Order || succ.Value == follow || (hasBreak && succ.Value == brk) || visited[succ.ID()] {
+				continue
+			}
+			visited[succ.ID()] = true
+			set[succ.Value] = struct{}{}
+			work.push(succ)
+		}
+	}
+	return set
+}